@@ -0,0 +1,304 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"harry-pap/beat_assignment/calculator"
+	"harry-pap/beat_assignment/compression"
+	"harry-pap/beat_assignment/parser"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// sourceFlags are the flags of the commands reading rides, which set how the source is parsed
+type sourceFlags struct {
+	format          parser.Format
+	timestampFormat parser.TimestampFormat
+	header          parser.HeaderMode
+	columns         parser.ColumnMapping
+	grouping        parser.Grouping
+	inactivityGap   *time.Duration
+	maxOpenRides    *int
+	spillDir        *string
+	maxRejects      *int
+}
+
+// register defines the flags in the flag.FlagSet, with maxRejects as the default of -max-rejects
+func (source *sourceFlags) register(flags *flag.FlagSet, maxRejects int) {
+	source.maxRejects = flags.Int("max-rejects", maxRejects, "number of input lines that can be rejected before aborting, -1 for no limit")
+	flags.Var(&source.timestampFormat, "timestamp-format", "format of the input timestamps: seconds, milliseconds, microseconds, rfc3339 or auto")
+	flags.Var(&source.header, "header", "whether the first input line is a header: auto, yes or no")
+	flags.Var(&source.columns, "columns", "input columns of the fields, by header name or 0-based index, e.g. id_ride=ride,lat=latitude,lng=longitude,timestamp=ts")
+	flags.Var(&source.format, "input-format", "format of the source: csv, jsonl, parquet, or auto to detect it by the file extension")
+	flags.Var(&source.grouping, "grouping", "how input lines are grouped into rides: contiguous, or multiplexed for interleaved rides")
+	source.inactivityGap = flags.Duration("inactivity-gap", 0, "time after the last point of a multiplexed ride, after which it is completed, 0 for no limit")
+	source.maxOpenRides = flags.Int("max-open-rides", 10000, "number of multiplexed rides buffered in memory before spilling to disk, 0 for no limit")
+	source.spillDir = flags.String("spill-dir", "", "directory of the spill file of multiplexed rides, the temporary directory when empty")
+}
+
+// numberArguments returns the numeric flags, with their minimum value
+func (source *sourceFlags) numberArguments() []numberArgument {
+	return []numberArgument{
+		{"max-rejects", float64(*source.maxRejects), -1},
+		{"max-open-rides", float64(*source.maxOpenRides), 0},
+		{"inactivity-gap", float64(*source.inactivityGap), 0},
+	}
+}
+
+// options returns the parser.Options of the flags, without Rejects
+func (source *sourceFlags) options() parser.Options {
+	return parser.Options{MaxRejects: *source.maxRejects, TimestampFormat: source.timestampFormat, Header: source.header,
+		Columns: source.columns, Grouping: source.grouping, InactivityGap: *source.inactivityGap,
+		MaxOpenRides: *source.maxOpenRides, SpillDir: *source.spillDir}
+}
+
+// open opens the source at path, in the format of the flags, or else of its extension
+func (source *sourceFlags) open(ctx context.Context, path string, options parser.Options) (parser.RideSource, error) {
+	return openSource(ctx, path, source.format.Resolve(path), options)
+}
+
+// fareFlags are the flags of the commands calculating fares, which set the tariff, and how the points of each ride
+// are sorted
+type fareFlags struct {
+	tariffPath    *string
+	reorder       *bool
+	reorderWindow *int
+}
+
+// register defines the flags in the flag.FlagSet
+func (fare *fareFlags) register(flags *flag.FlagSet) {
+	fare.tariffPath = flags.String("tariff", "", "JSON or YAML tariff file, the built-in tariff is used when empty")
+	fare.reorder = flags.Bool("reorder", false, "sort the points of each ride by timestamp, before discarding the invalid ones")
+	fare.reorderWindow = flags.Int("reorder-window", 0, "number of points a point can be late by and still be sorted, used with -reorder, 0 for the whole ride")
+}
+
+// numberArguments returns the numeric flags, with their minimum value
+func (fare *fareFlags) numberArguments() []numberArgument {
+	return []numberArgument{{"reorder-window", float64(*fare.reorderWindow), 0}}
+}
+
+// tariff returns the tariff of -tariff
+func (fare *fareFlags) tariff() (calculator.Tariff, error) {
+	return loadTariff(*fare.tariffPath)
+}
+
+// reorderParts returns the parts of a ride sorted with calculator.ReorderParts, and the number of parts moved, or the
+// parts unchanged without -reorder
+func (fare *fareFlags) reorderParts(parts []calculator.RidePart) ([]calculator.RidePart, int) {
+	if !*fare.reorder {
+		return parts, 0
+	}
+	return calculator.ReorderParts(parts, *fare.reorderWindow)
+}
+
+// loadTariff returns the tariff of the file at path, or calculator.DefaultTariff when the path is empty
+func loadTariff(path string) (calculator.Tariff, error) {
+	if path == "" {
+		return calculator.DefaultTariff, nil
+	}
+
+	tariff, err := calculator.LoadTariff(path)
+	if err != nil {
+		return calculator.Tariff{}, fmt.Errorf("cannot load tariff: %w", err)
+	}
+	return tariff, nil
+}
+
+// openedFiles are the files opened by a command, which are closed together
+type openedFiles []io.Closer
+
+// Close closes every file, and returns their errors joined
+func (files openedFiles) Close() error {
+	errs := make([]error, 0, len(files))
+	for _, file := range files {
+		errs = append(errs, file.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// positional is an argument of a command, which can also be set with a flag
+type positional struct {
+	name  string
+	value *string
+	// optional arguments are left empty when missing, instead of returning an error
+	optional bool
+}
+
+// resolvePositionals sets the positionals that are not set with their flag, from the arguments, in order, and returns
+// an error for a missing positional, or for extra arguments
+func resolvePositionals(args []string, positionals ...positional) error {
+	for _, argument := range positionals {
+		if *argument.value == "" && len(args) > 0 {
+			*argument.value, args = args[0], args[1:]
+		}
+	}
+
+	if len(args) > 0 {
+		return fmt.Errorf("unexpected arguments %q", args)
+	}
+	for _, argument := range positionals {
+		if *argument.value == "" && !argument.optional {
+			return fmt.Errorf("missing %s", argument.name)
+		}
+	}
+	return nil
+}
+
+// numberArgument is the value of a numeric flag of the flag.FlagSet, with its minimum value
+type numberArgument struct {
+	name    string
+	value   float64
+	minimum float64
+}
+
+// validateArguments returns the errors of the flags below their minimum, of sources that do not exist, and of a
+// target that is one of the sources, joined. The target is not checked when empty
+func validateArguments(flags *flag.FlagSet, numbers []numberArgument, sourcePaths []string, targetPath string) error {
+	errs := make([]error, 0, len(numbers)+2*len(sourcePaths))
+	for _, number := range numbers {
+		if number.value < number.minimum {
+			errs = append(errs, fmt.Errorf("-%s must be at least %v, got %s", number.name, number.minimum, flags.Lookup(number.name).Value))
+		}
+	}
+
+	for _, sourcePath := range sourcePaths {
+		if sourcePath == compression.StdStream {
+			continue
+		}
+
+		if _, err := os.Stat(sourcePath); err != nil {
+			errs = append(errs, fmt.Errorf("cannot read source: %w", err))
+		}
+		if sourcePath == targetPath {
+			errs = append(errs, fmt.Errorf("target %s would overwrite the source", targetPath))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// reportPath is the path of a report written besides the target, given with the flag name
+type reportPath struct {
+	name     string
+	path     string
+	required bool
+}
+
+// validateReports returns the errors of the reports that are required but missing, or that would overwrite the source
+// or the target
+func validateReports(sourcePath string, targetPath string, reports ...reportPath) error {
+	errs := make([]error, 0, len(reports))
+	for _, report := range reports {
+		switch {
+		case report.path == "":
+			if report.required {
+				errs = append(errs, fmt.Errorf("missing -%s", report.name))
+			}
+		case report.path == sourcePath:
+			errs = append(errs, fmt.Errorf("-%s %s would overwrite the source", report.name, report.path))
+		case report.path == targetPath:
+			errs = append(errs, fmt.Errorf("-%s %s would overwrite the target", report.name, report.path))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// pathList is a flag.Value collecting the paths of a repeated flag
+type pathList []string
+
+func (paths *pathList) String() string {
+	return strings.Join(*paths, ",")
+}
+
+func (paths *pathList) Set(text string) error {
+	*paths = append(*paths, text)
+	return nil
+}
+
+// rideIDList is a flag.Value collecting ride ids, separated by commas, from a flag that can be repeated
+type rideIDList []int64
+
+func (ids *rideIDList) String() string {
+	texts := make([]string, 0, len(*ids))
+	for _, id := range *ids {
+		texts = append(texts, strconv.FormatInt(id, 10))
+	}
+	return strings.Join(texts, ",")
+}
+
+func (ids *rideIDList) Set(text string) error {
+	for _, field := range strings.Split(text, ",") {
+		id, err := strconv.ParseInt(strings.TrimSpace(field), 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid ride id %q", field)
+		}
+		*ids = append(*ids, id)
+	}
+	return nil
+}
+
+// openSource opens the source, and returns the RideSource reading it in the given format, which closes the file too.
+// Parquet sources are read at random, so they cannot be read from the standard input, and compressed ones are
+// rejected. Once the context is cancelled, the reads of the other sources return its error, even while blocked on a
+// stalled standard input
+func openSource(ctx context.Context, path string, format parser.Format, options parser.Options) (parser.RideSource, error) {
+	if format == parser.FormatParquet {
+		if path == compression.StdStream {
+			return nil, fmt.Errorf("parquet input cannot be read from the standard input")
+		}
+
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+
+		if compression.IsCompressed(file) {
+			file.Close()
+			return nil, fmt.Errorf("parquet input %s is compressed, it must be decompressed first, as it is read at random", path)
+		}
+
+		info, err := file.Stat()
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+
+		source, err := parser.NewParquetSource(file, info.Size(), options)
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+		return fileSource{RideSource: source, file: file}, nil
+	}
+
+	file, err := compression.OpenInputContext(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+
+	if format == parser.FormatJSONL {
+		return fileSource{RideSource: parser.NewJSONLSource(file, options), file: file}, nil
+	}
+	return fileSource{RideSource: parser.NewCSVSource(file, options), file: file}, nil
+}
+
+// fileSource is a RideSource, which closes the file it reads when closed
+type fileSource struct {
+	parser.RideSource
+	file io.Closer
+}
+
+func (source fileSource) Close() error {
+	err := source.RideSource.Close()
+
+	if closeErr := source.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
@@ -0,0 +1,84 @@
+package audit
+
+import (
+	"harry-pap/beat_assignment/model"
+	"sort"
+)
+
+// DriverReport aggregates the estimations of rides checked by an Auditor, by the driver who charged them
+type DriverReport struct {
+	auditor Auditor
+	drivers map[string]*driverRides
+}
+
+type driverRides struct {
+	flagged         int
+	totalOvercharge float64
+	deviations      []float64
+}
+
+// NewDriverReport creates an empty DriverReport, whose rides are checked with the given Auditor
+func NewDriverReport(auditor Auditor) *DriverReport {
+	return &DriverReport{auditor: auditor, drivers: make(map[string]*driverRides)}
+}
+
+// Add checks the estimation, and adds it to the rides of its driver. Rides with no charged fare are ignored
+func (report *DriverReport) Add(estimation model.RideFareEstimation) {
+	charge, found := report.auditor.Charges[estimation.RideID]
+	if !found {
+		return
+	}
+
+	rides, found := report.drivers[charge.DriverID]
+	if !found {
+		rides = &driverRides{}
+		report.drivers[charge.DriverID] = rides
+	}
+
+	flaggedRide, flagged := report.auditor.Check(estimation)
+	if flagged {
+		rides.flagged++
+		rides.totalOvercharge += flaggedRide.Deviation
+	}
+	rides.deviations = append(rides.deviations, flaggedRide.Deviation)
+}
+
+// Summaries returns the summary of every driver, ranked for review: by total overcharge, then by flagged ratio,
+// both descending, then by driver id
+func (report *DriverReport) Summaries() []model.DriverSummary {
+	result := make([]model.DriverSummary, 0, len(report.drivers))
+
+	for driverID, rides := range report.drivers {
+		result = append(result, model.DriverSummary{
+			DriverID:        driverID,
+			Rides:           len(rides.deviations),
+			FlaggedRides:    rides.flagged,
+			FlaggedRatio:    float64(rides.flagged) / float64(len(rides.deviations)),
+			TotalOvercharge: rides.totalOvercharge,
+			MedianDeviation: median(rides.deviations),
+		})
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].TotalOvercharge != result[j].TotalOvercharge {
+			return result[i].TotalOvercharge > result[j].TotalOvercharge
+		}
+		if result[i].FlaggedRatio != result[j].FlaggedRatio {
+			return result[i].FlaggedRatio > result[j].FlaggedRatio
+		}
+		return result[i].DriverID < result[j].DriverID
+	})
+
+	return result
+}
+
+func median(values []float64) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+
+	middle := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[middle-1] + sorted[middle]) / 2
+	}
+	return sorted[middle]
+}
@@ -0,0 +1,75 @@
+package audit
+
+import (
+	"harry-pap/beat_assignment/model"
+	"math"
+	"testing"
+)
+
+func TestDriverReport_Summaries(t *testing.T) {
+	auditor := Auditor{
+		Charges: map[int64]model.ChargedFare{
+			1: {RideID: 1, DriverID: "driver-1", Charged: 30},
+			2: {RideID: 2, DriverID: "driver-1", Charged: 20},
+			3: {RideID: 3, DriverID: "driver-1", Charged: 19},
+			4: {RideID: 4, DriverID: "driver-2", Charged: 40},
+			5: {RideID: 5, DriverID: "driver-2", Charged: 20},
+			6: {RideID: 6, DriverID: "driver-3", Charged: 30},
+			7: {RideID: 7, DriverID: "driver-4", Charged: 20},
+		},
+		Tolerance: Tolerance{Absolute: 1, Percentage: 10},
+	}
+	estimations := []model.RideFareEstimation{
+		{RideID: 1, CostEstimation: 20},
+		{RideID: 2, CostEstimation: 20},
+		{RideID: 3, CostEstimation: 20},
+		{RideID: 4, CostEstimation: 30},
+		{RideID: 5, CostEstimation: 20},
+		{RideID: 6, CostEstimation: 20},
+		{RideID: 7, CostEstimation: 20},
+		{RideID: 8, CostEstimation: 20},
+	}
+	want := []model.DriverSummary{
+		{DriverID: "driver-3", Rides: 1, FlaggedRides: 1, FlaggedRatio: 1, TotalOvercharge: 10, MedianDeviation: 10},
+		{DriverID: "driver-2", Rides: 2, FlaggedRides: 1, FlaggedRatio: 0.5, TotalOvercharge: 10, MedianDeviation: 5},
+		{DriverID: "driver-1", Rides: 3, FlaggedRides: 1, FlaggedRatio: 1.0 / 3, TotalOvercharge: 10, MedianDeviation: 0},
+		{DriverID: "driver-4", Rides: 1, FlaggedRides: 0, FlaggedRatio: 0, TotalOvercharge: 0, MedianDeviation: 0},
+	}
+
+	report := NewDriverReport(auditor)
+	for _, estimation := range estimations {
+		report.Add(estimation)
+	}
+
+	got := report.Summaries()
+	if len(got) != len(want) {
+		t.Fatalf("DriverReport.Summaries() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i].DriverID != want[i].DriverID || got[i].Rides != want[i].Rides || got[i].FlaggedRides != want[i].FlaggedRides ||
+			math.Abs(got[i].FlaggedRatio-want[i].FlaggedRatio) > 0.0001 ||
+			math.Abs(got[i].TotalOvercharge-want[i].TotalOvercharge) > 0.0001 ||
+			math.Abs(got[i].MedianDeviation-want[i].MedianDeviation) > 0.0001 {
+			t.Errorf("DriverReport.Summaries()[%d] = %v, want %v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestMedian(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []float64
+		want   float64
+	}{
+		{"single value", []float64{3}, 3},
+		{"odd number of values", []float64{5, -1, 3}, 3},
+		{"even number of values", []float64{5, -1, 3, 10}, 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := median(tt.values); got != tt.want {
+				t.Errorf("median() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
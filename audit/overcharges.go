@@ -0,0 +1,45 @@
+package audit
+
+import (
+	"harry-pap/beat_assignment/model"
+	"math"
+)
+
+// Tolerance contains how much a charged fare can exceed its estimation, before the ride is flagged.
+// A ride is flagged when the overcharge exceeds both the Absolute amount and the Percentage of the estimation
+type Tolerance struct {
+	Absolute   float64
+	Percentage float64
+}
+
+// Auditor compares the estimations of rides with the fares charged for them
+type Auditor struct {
+	Charges   map[int64]model.ChargedFare
+	Tolerance Tolerance
+}
+
+// Check joins the estimation with the charged fare of the same ride, and returns the resulting FlaggedRide,
+// and whether it exceeds the tolerance. Rides with no charged fare are never flagged
+func (auditor Auditor) Check(estimation model.RideFareEstimation) (model.FlaggedRide, bool) {
+	charge, found := auditor.Charges[estimation.RideID]
+	if !found {
+		return model.FlaggedRide{}, false
+	}
+
+	deviation := charge.Charged - estimation.CostEstimation
+	percentage := math.Inf(1)
+	if estimation.CostEstimation > 0 {
+		percentage = deviation / estimation.CostEstimation * 100
+	}
+
+	flaggedRide := model.FlaggedRide{
+		RideID:              estimation.RideID,
+		DriverID:            charge.DriverID,
+		CostEstimation:      estimation.CostEstimation,
+		Charged:             charge.Charged,
+		Deviation:           deviation,
+		DeviationPercentage: percentage,
+	}
+
+	return flaggedRide, deviation > auditor.Tolerance.Absolute && percentage > auditor.Tolerance.Percentage
+}
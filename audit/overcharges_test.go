@@ -0,0 +1,74 @@
+package audit
+
+import (
+	"harry-pap/beat_assignment/model"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestAuditor_Check(t *testing.T) {
+	auditor := Auditor{
+		Charges: map[int64]model.ChargedFare{
+			1: {RideID: 1, DriverID: "driver-1", Charged: 30},
+			2: {RideID: 2, DriverID: "driver-1", Charged: 20.5},
+			3: {RideID: 3, DriverID: "driver-2", Charged: 21.5},
+			4: {RideID: 4, DriverID: "driver-2", Charged: 15},
+		},
+		Tolerance: Tolerance{Absolute: 1, Percentage: 10},
+	}
+	type want struct {
+		res     model.FlaggedRide
+		flagged bool
+	}
+	tests := []struct {
+		name       string
+		estimation model.RideFareEstimation
+		want       want
+	}{
+		{
+			"overcharge exceeding both tolerances is flagged",
+			model.RideFareEstimation{RideID: 1, CostEstimation: 20},
+			want{model.FlaggedRide{RideID: 1, DriverID: "driver-1", CostEstimation: 20, Charged: 30, Deviation: 10, DeviationPercentage: 50}, true},
+		},
+		{
+			"overcharge within the absolute tolerance is not flagged",
+			model.RideFareEstimation{RideID: 2, CostEstimation: 20},
+			want{model.FlaggedRide{RideID: 2, DriverID: "driver-1", CostEstimation: 20, Charged: 20.5, Deviation: 0.5, DeviationPercentage: 2.5}, false},
+		},
+		{
+			"overcharge within the percentage tolerance is not flagged",
+			model.RideFareEstimation{RideID: 3, CostEstimation: 20},
+			want{model.FlaggedRide{RideID: 3, DriverID: "driver-2", CostEstimation: 20, Charged: 21.5, Deviation: 1.5, DeviationPercentage: 7.5}, false},
+		},
+		{
+			"undercharge is not flagged",
+			model.RideFareEstimation{RideID: 4, CostEstimation: 20},
+			want{model.FlaggedRide{RideID: 4, DriverID: "driver-2", CostEstimation: 20, Charged: 15, Deviation: -5, DeviationPercentage: -25}, false},
+		},
+		{
+			"ride without a charged fare is not flagged",
+			model.RideFareEstimation{RideID: 5, CostEstimation: 20},
+			want{model.FlaggedRide{}, false},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, flagged := auditor.Check(tt.estimation)
+			if flagged != tt.want.flagged || !equalFlaggedRide(got, tt.want.res) {
+				t.Errorf("Auditor.Check() = %v, %v, want %v", got, flagged, tt.want)
+			}
+		})
+	}
+}
+
+func equalFlaggedRide(x, y model.FlaggedRide) bool {
+	const tolerance = 0.0001
+	x.Deviation, y.Deviation = round(x.Deviation, tolerance), round(y.Deviation, tolerance)
+	x.DeviationPercentage, y.DeviationPercentage = round(x.DeviationPercentage, tolerance), round(y.DeviationPercentage, tolerance)
+	return reflect.DeepEqual(x, y)
+}
+
+func round(x, unit float64) float64 {
+	return math.Round(x/unit) * unit
+}
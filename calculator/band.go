@@ -0,0 +1,91 @@
+package calculator
+
+import (
+	"fmt"
+	"time"
+)
+
+const minutesPerDay = ClockTime(24 * 60)
+
+// dayNames maps the day names that can be used in Band.Days, to the weekdays they stand for.
+// holiday stands for no weekday, as it applies to the Tariff.Holidays instead
+var dayNames = map[string][]time.Weekday{
+	"mon":     {time.Monday},
+	"tue":     {time.Tuesday},
+	"wed":     {time.Wednesday},
+	"thu":     {time.Thursday},
+	"fri":     {time.Friday},
+	"sat":     {time.Saturday},
+	"sun":     {time.Sunday},
+	"weekday": {time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday},
+	"weekend": {time.Saturday, time.Sunday},
+	"holiday": {},
+}
+
+// Band is a part of the tariff schedule, with the fares charged between From(inclusive) and To(exclusive), on the given Days.
+// Days can contain mon-sun, weekday, weekend and holiday, and applies to every day when empty.
+// When From is after To the band wraps around midnight, and when From equals To the band applies to the whole day.
+// When many bands apply to the same time, the first one of the tariff is used
+type Band struct {
+	Name            string    `json:"name" yaml:"name"`
+	Days            []string  `json:"days" yaml:"days"`
+	From            ClockTime `json:"from" yaml:"from"`
+	To              ClockTime `json:"to" yaml:"to"`
+	FarePerKm       float64   `json:"fare_per_km" yaml:"fare_per_km"`
+	IdleFarePerHour float64   `json:"idle_fare_per_hour" yaml:"idle_fare_per_hour"`
+}
+
+// ClockTime is a time of day, in minutes after midnight, written as HH:MM from 00:00 to 24:00
+type ClockTime int
+
+// UnmarshalText parses a HH:MM time of day
+func (clockTime *ClockTime) UnmarshalText(text []byte) error {
+	var hours, minutes int
+	var rest string
+
+	if n, _ := fmt.Sscanf(string(text), "%d:%d%s", &hours, &minutes, &rest); n != 2 ||
+		hours < 0 || minutes < 0 || minutes > 59 || hours*60+minutes > int(minutesPerDay) {
+		return fmt.Errorf("invalid time of day %q, expected HH:MM from 00:00 to 24:00", text)
+	}
+
+	*clockTime = ClockTime(hours*60 + minutes)
+	return nil
+}
+
+// MarshalText formats the time of day as HH:MM
+func (clockTime ClockTime) MarshalText() ([]byte, error) {
+	return []byte(clockTime.String()), nil
+}
+
+func (clockTime ClockTime) String() string {
+	return fmt.Sprintf("%02d:%02d", clockTime/60, clockTime%60)
+}
+
+func (band Band) appliesOn(weekday time.Weekday, isHoliday bool) bool {
+	if len(band.Days) == 0 {
+		return true
+	}
+
+	for _, day := range band.Days {
+		if day == "holiday" && isHoliday {
+			return true
+		}
+		for _, candidate := range dayNames[day] {
+			if candidate == weekday {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (band Band) contains(minute ClockTime) bool {
+	switch {
+	case band.From == band.To:
+		return true
+	case band.From < band.To:
+		return minute >= band.From && minute < band.To
+	default:
+		return minute >= band.From || minute < band.To
+	}
+}
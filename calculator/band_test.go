@@ -0,0 +1,50 @@
+package calculator
+
+import "testing"
+
+func TestClockTime_UnmarshalText(t *testing.T) {
+	tests := []struct {
+		text    string
+		want    ClockTime
+		wantErr bool
+	}{
+		{"00:00", 0, false},
+		{"05:30", 5*60 + 30, false},
+		{"24:00", 24 * 60, false},
+		{"24:01", 0, true},
+		{"12:60", 0, true},
+		{"-01:00", 0, true},
+		{"12:00pm", 0, true},
+		{"noon", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			var got ClockTime
+			if err := got.UnmarshalText([]byte(tt.text)); (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("ClockTime.UnmarshalText() = %v, %v, want %v, error: %v", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestBand_contains(t *testing.T) {
+	tests := []struct {
+		name   string
+		band   Band
+		minute ClockTime
+		want   bool
+	}{
+		{"inside a band", Band{From: 60, To: 120}, 90, true},
+		{"before a band", Band{From: 60, To: 120}, 30, false},
+		{"inside a band wrapping around midnight", Band{From: 23 * 60, To: 60}, 30, true},
+		{"outside a band wrapping around midnight", Band{From: 23 * 60, To: 60}, 12 * 60, false},
+		{"whole day band", Band{}, 12 * 60, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.band.contains(tt.minute); got != tt.want {
+				t.Errorf("Band.contains() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -2,36 +2,40 @@ package calculator
 
 import (
 	"errors"
-	"fmt"
 	"harry-pap/beat_assignment/model"
 	"math"
 	"time"
 )
 
-const (
-	dayFarePerKm           = 0.74
-	nightFarePerKm         = 1.30
-	idleFarePerHour        = 11.90
-	earthRadius            = float64(6371)
-	minimumRide            = 3.47
-	flagValue              = 1.30
-	minimumTimeSlotInHours = 0.0001
-)
+const earthRadius = float64(6371)
 
 var errNotEnoughSegments = errors.New("not_enough_segments")
 var errInvalidTimestamp = errors.New("end_timestamp_not_greater_than_start")
 
+// failureCalculation is the kind of the calculation errors other than the ones above
+const failureCalculation = "calculation_failed"
+
+// FailureKind returns the kind of error of a ride whose fare could not be calculated, as written in its failure record
+func FailureKind(err error) string {
+	for _, known := range []error{errNotEnoughSegments, errInvalidTimestamp} {
+		if errors.Is(err, known) {
+			return known.Error()
+		}
+	}
+	return failureCalculation
+}
+
 // Coordinate represents a position with Latitude and Longitude
 type Coordinate struct {
 	Latitude  float64
 	Longitude float64
 }
 
-// RidePart represents a part of a given ride, with a RideID, a Coordinate and a Unix timestamp
+// RidePart represents a part of a given ride, with a 64-bit RideID, a Coordinate and a Unix timestamp in nanoseconds
 type RidePart struct {
 	RideID     int64
 	Coordinate Coordinate
-	Timestamp  int32
+	Timestamp  int64
 }
 
 // RideSegment represents a segment of two RideParts
@@ -40,41 +44,109 @@ type RideSegment struct {
 	End   RidePart
 }
 
-// CalculateFareForRide calculates the fare of the ride. Invalid ride parts(where speed is over 100km/hour) are not included
-// If the cost is less than that of the minimum fare(3.47), then the minimum fare is returned.
-func CalculateFareForRide(entries []RidePart) (model.RideFareEstimation, error) {
-	segments := GetValidSegments(entries)
+// CalculateFareForRide calculates the fare of the ride using the given Tariff. Invalid ride parts(where speed is over 100km/hour) are not included
+// If the cost is less than that of the minimum fare(Tariff.MinimumRide), then the minimum fare is returned.
+// The returned estimation contains the breakdown of the fare, with the moving km and cost of each band of the Tariff, in its order.
+// A ride without a valid segment returns errInvalidTimestamp when every point after the first one is timestamped before
+// it, and errNotEnoughSegments otherwise.
+func CalculateFareForRide(entries []RidePart, tariff Tariff) (model.RideFareEstimation, error) {
+	checks := filterSegments(entries)
+	segments := validSegments(entries, checks)
 
 	if len(segments) == 0 {
-		return model.RideFareEstimation{}, errNotEnoughSegments
+		return model.RideFareEstimation{}, noSegmentsError(checks)
+	}
+
+	breakdown := model.FareBreakdown{
+		Flag:            tariff.FlagValue,
+		Bands:           make([]model.BandCharge, len(tariff.Bands)),
+		DiscardedPoints: len(entries) - len(segments) - 1,
+	}
+	for i, band := range tariff.Bands {
+		breakdown.Bands[i].Name = band.Name
 	}
-	sum := flagValue
+
+	sum := tariff.FlagValue
 
 	for _, segment := range segments {
-		sum += segment.GetFare()
+		for _, charge := range segment.charges(tariff) {
+			if charge.idle {
+				breakdown.IdleHours += charge.hours
+				breakdown.IdleCost += charge.cost
+			} else if charge.band >= 0 {
+				breakdown.Bands[charge.band].MovingKm += charge.km
+				breakdown.Bands[charge.band].MovingCost += charge.cost
+			}
+			sum += charge.cost
+		}
 	}
 
-	if sum < minimumRide {
-		sum = minimumRide
+	if sum < tariff.MinimumRide {
+		breakdown.MinimumTopUp = tariff.MinimumRide - sum
+		sum = tariff.MinimumRide
 	}
 
-	return model.RideFareEstimation{RideID: entries[0].RideID, CostEstimation: sum}, nil
+	return model.RideFareEstimation{RideID: entries[0].RideID, CostEstimation: sum, Breakdown: breakdown}, nil
 }
 
 // GetValidSegments filters out the second part of segments, in which the speed is found to be > 100KM/H, as they are considered erroneous
+// The parts timestamped before the last valid part are filtered out too, and counted as discarded points
 func GetValidSegments(entries []RidePart) []RideSegment {
+	return validSegments(entries, filterSegments(entries))
+}
 
+// validSegments returns the segments of the valid checks
+func validSegments(entries []RidePart, checks []segmentCheck) []RideSegment {
 	result := make([]RideSegment, 0, len(entries))
 
-	for i, j := 0, 1; i < len(entries)-1 && j < len(entries); j++ {
-		segment := RideSegment{Start: entries[i], End: entries[j]}
+	for _, check := range checks {
+		if check.valid {
+			result = append(result, RideSegment{Start: entries[check.start], End: entries[check.end]})
+		}
+	}
 
-		isValid, err := segment.isValid()
-		if err != nil {
-			fmt.Println("Ignoring ", segment, " due to error: ", err)
-		} else if isValid {
-			result = append(result, segment)
+	return result
+}
 
+// noSegmentsError returns the error of a ride without a valid segment, whose checks all start from its first part:
+// errInvalidTimestamp when every other part is timestamped before it, errNotEnoughSegments otherwise
+func noSegmentsError(checks []segmentCheck) error {
+	if len(checks) == 0 {
+		return errNotEnoughSegments
+	}
+
+	for _, check := range checks {
+		if check.err == nil {
+			return errNotEnoughSegments
+		}
+	}
+	return errInvalidTimestamp
+}
+
+// maxValidKmPerHour is the speed of a segment above which its end is discarded
+const maxValidKmPerHour = 100
+
+// segmentCheck is a segment checked by filterSegments, from the part at index start to the one at index end
+type segmentCheck struct {
+	start     int
+	end       int
+	kmPerHour float64
+	err       error
+	valid     bool
+}
+
+// filterSegments checks the segment from the last valid part to each following part, whose end is valid when its speed
+// is at most maxValidKmPerHour, and returns the checks in order, one for every part but the first
+func filterSegments(entries []RidePart) []segmentCheck {
+	result := make([]segmentCheck, 0, len(entries))
+
+	for i, j := 0, 1; j < len(entries); j++ {
+		kmPerHour, err := CalculateKmPerHour(entries[i], entries[j])
+
+		check := segmentCheck{start: i, end: j, kmPerHour: kmPerHour, err: err, valid: err == nil && kmPerHour <= maxValidKmPerHour}
+		result = append(result, check)
+
+		if check.valid {
 			i = j
 		}
 	}
@@ -84,42 +156,95 @@ func GetValidSegments(entries []RidePart) []RideSegment {
 
 // CalculateKmPerHour calculates the speed between two given RideParts, in KM/H
 // An error is returned if: start timestamp > end timestamp
-// If: start timestamp == end timestamp, then time difference is set to 0.4 seconds
+// If: start timestamp == end timestamp, then the speed is infinite, or 0 if the coordinates are the same too
 func CalculateKmPerHour(start RidePart, end RidePart) (float64, error) {
 	kilometers := HarvestineInKilometers(
 		Coordinate{Latitude: start.Coordinate.Latitude, Longitude: start.Coordinate.Longitude},
 		Coordinate{Latitude: end.Coordinate.Latitude, Longitude: end.Coordinate.Longitude})
 
-	hours := secondsToHours(end.Timestamp - start.Timestamp)
+	hours := time.Duration(end.Timestamp - start.Timestamp).Hours()
 
 	if hours < 0 {
 		return 0, errInvalidTimestamp
 	} else if hours == 0 {
-		hours = minimumTimeSlotInHours
+		if kilometers == 0 {
+			return 0, nil
+		}
+		return math.Inf(1), nil
 	}
 	return kilometers / hours, nil
 }
 
-// GetFare calculates and returns the fare of a ride segment.
-// If the ride is NOT idle(speed is > 10 KM/H):
-//   - If the START timestamp is between 00:00:00 and 05:00:00(not inclusive), fare = 1.30 * km driven
-//   - If the START timestamp is between 05:00:00 and 00:00:00(not inclusive), fare = 0.74 * km driven
+// GetFare calculates and returns the fare of a ride segment, using the Band of the given Tariff that applies to
+// the time of day, the day of the week and the holidays, in the time zone of the Tariff.
+// If the ride is NOT idle(speed is > 10 KM/H): fare = Band.FarePerKm * km driven
+// If the ride is IDLE: fare = Band.IdleFarePerHour * segment hours
 //
-// If the ride is IDLE: fare = 11.90 * segment hours
-func (segment RideSegment) GetFare() float64 {
-	isIdle, err := segment.isIdle()
+// A segment crossing the boundary of a band is split at the boundary, the km driven are prorated by the time
+// spent on each side of it, and each part is charged with the fare of its own band.
+func (segment RideSegment) GetFare(tariff Tariff) float64 {
+	fare := float64(0)
 
-	if err != nil {
-		fmt.Println("Ignoring ", segment, " due to error: ", err)
-	} else if isIdle {
-		return idleFarePerHour * (secondsToHours(segment.End.Timestamp - segment.Start.Timestamp))
+	for _, charge := range segment.charges(tariff) {
+		fare += charge.cost
 	}
 
+	return fare
+}
+
+// segmentCharge is the part of a segment during which a single band applies
+type segmentCharge struct {
+	band  int
+	idle  bool
+	km    float64
+	hours float64
+	cost  float64
+}
+
+// charges splits the segment at the boundaries of the bands of the tariff, and charges each part with its band
+func (segment RideSegment) charges(tariff Tariff) []segmentCharge {
+	// A segment ending before its start is charged as moving, as it is never a valid segment of a ride
+	isIdle, _ := segment.isIdle()
+
 	kmDriven := HarvestineInKilometers(segment.Start.Coordinate, segment.End.Coordinate)
-	if isNightHours(int64(segment.Start.Timestamp)) {
-		return kmDriven * nightFarePerKm
+
+	start := time.Unix(0, segment.Start.Timestamp)
+	end := time.Unix(0, segment.End.Timestamp)
+	if !end.After(start) {
+		return []segmentCharge{newSegmentCharge(tariff, tariff.bandAt(start), isIdle, kmDriven, 0)}
+	}
+
+	duration := end.Sub(start).Seconds()
+	result := make([]segmentCharge, 0, 1)
+
+	for from := start; from.Before(end); {
+		to := tariff.nextBoundary(from)
+		if to.After(end) {
+			to = end
+		}
+
+		share := to.Sub(from).Seconds() / duration
+		result = append(result, newSegmentCharge(tariff, tariff.bandAt(from), isIdle, kmDriven*share, to.Sub(from).Hours()))
+
+		from = to
+	}
+
+	return result
+}
+
+func newSegmentCharge(tariff Tariff, band int, idle bool, km float64, hours float64) segmentCharge {
+	charge := segmentCharge{band: band, idle: idle, km: km, hours: hours}
+
+	if band < 0 {
+		return charge
+	}
+
+	if idle {
+		charge.cost = tariff.Bands[band].IdleFarePerHour * hours
+	} else {
+		charge.cost = tariff.Bands[band].FarePerKm * km
 	}
-	return kmDriven * dayFarePerKm
+	return charge
 }
 
 // HarvestineInKilometers uses the Harvestine formula, to calculate the distance between two Coordinates, in kilometers
@@ -138,18 +263,6 @@ func HarvestineInKilometers(from Coordinate, to Coordinate) float64 {
 	return earthRadius * c
 }
 
-func (segment RideSegment) isValid() (bool, error) {
-	kmPerHour, err := CalculateKmPerHour(segment.Start, segment.End)
-	if err != nil {
-		return false, err
-	}
-
-	if kmPerHour > 100 {
-		return false, nil
-	}
-	return true, nil
-}
-
 func (segment RideSegment) isIdle() (bool, error) {
 	kmPerHour, err := CalculateKmPerHour(segment.Start, segment.End)
 
@@ -162,13 +275,3 @@ func (segment RideSegment) isIdle() (bool, error) {
 	}
 	return true, nil
 }
-
-func secondsToHours(seconds int32) float64 {
-	return (time.Duration(seconds) * time.Second).Hours()
-}
-
-func isNightHours(timestamp int64) bool {
-	ts := time.Unix(timestamp, 0).UTC()
-
-	return ts.Hour() >= 0 && ts.Hour() < 5
-}
@@ -1,6 +1,7 @@
 package calculator
 
 import (
+	"errors"
 	"fmt"
 	"harry-pap/beat_assignment/model"
 	"math"
@@ -9,6 +10,14 @@ import (
 	"time"
 )
 
+// The fares of DefaultTariff
+const (
+	dayFarePerKm    = 0.74
+	nightFarePerKm  = 1.30
+	idleFarePerHour = 11.90
+	flagValue       = 1.30
+)
+
 var (
 	Coord1Part1          = Coordinate{Latitude: 51.365184, Longitude: -2.388245}
 	Coord1Part2          = Coordinate{Latitude: 52.052135, Longitude: -1.269958}
@@ -73,29 +82,50 @@ func TestCalculateKmPerHour(t *testing.T) {
 	}{
 		{"calculates expected speed for coord1",
 			args{
-				start: RidePart{1, Coord1Part1, int32(parseDatetime("2018-12-12T11:45:00Z").Unix())},
-				end:   RidePart{1, Coord1Part2, int32(parseDatetime("2018-12-12T12:45:00Z").Unix())},
+				start: RidePart{1, Coord1Part1, parseDatetime("2018-12-12T11:45:00Z").UnixNano()},
+				end:   RidePart{1, Coord1Part2, parseDatetime("2018-12-12T12:45:00Z").UnixNano()},
 			},
 			result{Coord1Part12Distance, nil},
 		},
 		{"calculates expected speed for coord2",
 			args{
-				start: RidePart{1, Coord2Part1, int32(parseDatetime("2018-12-12T11:00:00Z").Unix())},
-				end:   RidePart{1, Coord2Part2, int32(parseDatetime("2018-12-12T11:30:00Z").Unix())},
+				start: RidePart{1, Coord2Part1, parseDatetime("2018-12-12T11:00:00Z").UnixNano()},
+				end:   RidePart{1, Coord2Part2, parseDatetime("2018-12-12T11:30:00Z").UnixNano()},
 			},
 			result{Coord2Part12Distance * 2, nil},
 		},
 		{"calculates expected speed for coord2 when start == end",
 			args{
-				start: RidePart{1, Coord2Part1, int32(parseDatetime("2018-12-12T11:00:00Z").Unix())},
-				end:   RidePart{1, Coord2Part2, int32(parseDatetime("2018-12-12T11:00:00Z").Unix())},
+				start: RidePart{1, Coord2Part1, parseDatetime("2018-12-12T11:00:00Z").UnixNano()},
+				end:   RidePart{1, Coord2Part2, parseDatetime("2018-12-12T11:00:00Z").UnixNano()},
+			},
+			result{math.Inf(1), nil},
+		},
+		{"calculates zero speed for the same coordinates when start == end",
+			args{
+				start: RidePart{1, Coord2Part1, parseDatetime("2018-12-12T11:00:00Z").UnixNano()},
+				end:   RidePart{1, Coord2Part1, parseDatetime("2018-12-12T11:00:00Z").UnixNano()},
+			},
+			result{0, nil},
+		},
+		{"calculates expected speed with sub-second timestamps",
+			args{
+				start: RidePart{1, Coord2Part1, parseDatetime("2018-12-12T11:00:00.5Z").UnixNano()},
+				end:   RidePart{1, Coord2Part2, parseDatetime("2018-12-12T11:00:36.5Z").UnixNano()},
 			},
-			result{Coord2Part12Distance / minimumTimeSlotInHours, nil},
+			result{Coord2Part12Distance * 100, nil},
+		},
+		{"calculates expected speed across 2038-01-19T03:14:07Z",
+			args{
+				start: RidePart{1, Coord2Part1, parseDatetime("2038-01-19T03:00:00Z").UnixNano()},
+				end:   RidePart{1, Coord2Part2, parseDatetime("2038-01-19T03:30:00Z").UnixNano()},
+			},
+			result{Coord2Part12Distance * 2, nil},
 		},
 		{"returns an error when start > end",
 			args{
-				start: RidePart{1, Coord2Part1, int32(parseDatetime("2018-12-12T11:00:00Z").Unix())},
-				end:   RidePart{1, Coord2Part2, int32(parseDatetime("2018-12-12T10:00:00Z").Unix())},
+				start: RidePart{1, Coord2Part1, parseDatetime("2018-12-12T11:00:00Z").UnixNano()},
+				end:   RidePart{1, Coord2Part2, parseDatetime("2018-12-12T10:00:00Z").UnixNano()},
 			},
 			result{0, errInvalidTimestamp},
 		},
@@ -122,28 +152,28 @@ func TestGetValidSegments(t *testing.T) {
 		{
 			"all segments are valid and are retained",
 			args{[]RidePart{
-				{1, Coord1Part1, int32(parseDatetime("2018-12-12T11:45:00Z").Unix())},
-				{1, Coord1Part2, int32(parseDatetime("2018-12-12T13:45:00Z").Unix())},
-				{1, coord1Part3, int32(parseDatetime("2018-12-12T15:45:00Z").Unix())},
+				{1, Coord1Part1, parseDatetime("2018-12-12T11:45:00Z").UnixNano()},
+				{1, Coord1Part2, parseDatetime("2018-12-12T13:45:00Z").UnixNano()},
+				{1, coord1Part3, parseDatetime("2018-12-12T15:45:00Z").UnixNano()},
 			},
 			},
 			[]RideSegment{
-				{RidePart{1, Coord1Part1, int32(parseDatetime("2018-12-12T11:45:00Z").Unix())},
-					RidePart{1, Coord1Part2, int32(parseDatetime("2018-12-12T13:45:00Z").Unix())}},
-				{RidePart{1, Coord1Part2, int32(parseDatetime("2018-12-12T13:45:00Z").Unix())},
-					RidePart{1, coord1Part3, int32(parseDatetime("2018-12-12T15:45:00Z").Unix())}},
+				{RidePart{1, Coord1Part1, parseDatetime("2018-12-12T11:45:00Z").UnixNano()},
+					RidePart{1, Coord1Part2, parseDatetime("2018-12-12T13:45:00Z").UnixNano()}},
+				{RidePart{1, Coord1Part2, parseDatetime("2018-12-12T13:45:00Z").UnixNano()},
+					RidePart{1, coord1Part3, parseDatetime("2018-12-12T15:45:00Z").UnixNano()}},
 			}},
 		{
 			"the invalid segments are discarded",
 			args{[]RidePart{
-				{1, Coord1Part1, int32(parseDatetime("2018-12-12T11:45:00Z").Unix())},
-				{1, Coord1Part2, int32(parseDatetime("2018-12-12T11:45:01Z").Unix())},
-				{1, coord1Part3, int32(parseDatetime("2018-12-12T15:45:00Z").Unix())},
+				{1, Coord1Part1, parseDatetime("2018-12-12T11:45:00Z").UnixNano()},
+				{1, Coord1Part2, parseDatetime("2018-12-12T11:45:01Z").UnixNano()},
+				{1, coord1Part3, parseDatetime("2018-12-12T15:45:00Z").UnixNano()},
 			},
 			},
 			[]RideSegment{
-				{RidePart{1, Coord1Part1, int32(parseDatetime("2018-12-12T11:45:00Z").Unix())},
-					RidePart{1, coord1Part3, int32(parseDatetime("2018-12-12T15:45:00Z").Unix())}},
+				{RidePart{1, Coord1Part1, parseDatetime("2018-12-12T11:45:00Z").UnixNano()},
+					RidePart{1, coord1Part3, parseDatetime("2018-12-12T15:45:00Z").UnixNano()}},
 			}},
 	}
 	for _, tt := range tests {
@@ -166,18 +196,18 @@ func TestRideSegment_GetFare(t *testing.T) {
 		want float64
 	}{
 		{"day fare",
-			args{RidePart{1, Coord1Part1, int32(parseDatetime("2018-12-12T11:45:00Z").Unix())},
-				RidePart{1, Coord1Part2, int32(parseDatetime("2018-12-12T13:45:00Z").Unix())}},
+			args{RidePart{1, Coord1Part1, parseDatetime("2018-12-12T11:45:00Z").UnixNano()},
+				RidePart{1, Coord1Part2, parseDatetime("2018-12-12T13:45:00Z").UnixNano()}},
 			Coord1Part12Distance * dayFarePerKm,
 		},
 		{"night fare",
-			args{RidePart{1, Coord1Part1, int32(parseDatetime("2018-12-12T01:45:00Z").Unix())},
-				RidePart{1, Coord1Part2, int32(parseDatetime("2018-12-12T03:45:00Z").Unix())}},
+			args{RidePart{1, Coord1Part1, parseDatetime("2018-12-12T01:45:00Z").UnixNano()},
+				RidePart{1, Coord1Part2, parseDatetime("2018-12-12T03:45:00Z").UnixNano()}},
 			Coord1Part12Distance * nightFarePerKm,
 		},
 		{"idle fare",
-			args{RidePart{1, Coord1Part1, int32(parseDatetime("2018-12-12T01:45:00Z").Unix())},
-				RidePart{1, Coord2Part1, int32(parseDatetime("2018-12-12T03:45:00Z").Unix())}},
+			args{RidePart{1, Coord1Part1, parseDatetime("2018-12-12T01:45:00Z").UnixNano()},
+				RidePart{1, Coord2Part1, parseDatetime("2018-12-12T03:45:00Z").UnixNano()}},
 			2 * idleFarePerHour,
 		},
 	}
@@ -187,7 +217,139 @@ func TestRideSegment_GetFare(t *testing.T) {
 				Start: tt.args.Start,
 				End:   tt.args.End,
 			}
-			if got := segment.GetFare(); !Equal(got, tt.want, 0.01) {
+			if got := segment.GetFare(DefaultTariff); !Equal(got, tt.want, 0.01) {
+				t.Errorf("RideSegment.GetFare() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRideSegment_GetFare_timeZone(t *testing.T) {
+	athens, err := DefaultTariff.InTimeZone("Europe/Athens")
+	if err != nil {
+		t.Fatalf("Failed to load time zone, %s", err)
+	}
+	type args struct {
+		tariff Tariff
+		start  string
+		end    string
+	}
+	tests := []struct {
+		name string
+		args args
+		want float64
+	}{
+		{"03:30 UTC is night time in UTC",
+			args{DefaultTariff, "2018-12-12T03:30:00Z", "2018-12-12T03:33:00Z"},
+			Coord3Part34Distance * nightFarePerKm,
+		},
+		{"03:30 UTC is day time in Athens(05:30 EET)",
+			args{athens, "2018-12-12T03:30:00Z", "2018-12-12T03:33:00Z"},
+			Coord3Part34Distance * dayFarePerKm,
+		},
+		{"22:30 UTC is night time in Athens(00:30 EET)",
+			args{athens, "2018-12-11T22:30:00Z", "2018-12-11T22:33:00Z"},
+			Coord3Part34Distance * nightFarePerKm,
+		},
+		{"after the spring DST transition 02:30 UTC is day time in Athens(05:30 EEST)",
+			args{athens, "2018-03-25T02:30:00Z", "2018-03-25T02:33:00Z"},
+			Coord3Part34Distance * dayFarePerKm,
+		},
+		{"during the spring DST transition 01:30 UTC is night time in Athens(04:30 EEST)",
+			args{athens, "2018-03-25T01:30:00Z", "2018-03-25T01:33:00Z"},
+			Coord3Part34Distance * nightFarePerKm,
+		},
+		{"after the autumn DST transition 02:30 UTC is night time in Athens(04:30 EET)",
+			args{athens, "2018-10-28T02:30:00Z", "2018-10-28T02:33:00Z"},
+			Coord3Part34Distance * nightFarePerKm,
+		},
+		{"before the autumn DST transition 02:30 UTC is day time in Athens(05:30 EEST)",
+			args{athens, "2018-10-27T02:30:00Z", "2018-10-27T02:33:00Z"},
+			Coord3Part34Distance * dayFarePerKm,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			segment := RideSegment{
+				Start: RidePart{1, Coord3Part3, parseDatetime(tt.args.start).UnixNano()},
+				End:   RidePart{1, Coord3Part4, parseDatetime(tt.args.end).UnixNano()},
+			}
+			if got := segment.GetFare(tt.args.tariff); !Equal(got, tt.want, 0.01) {
+				t.Errorf("RideSegment.GetFare() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRideSegment_GetFare_boundaries(t *testing.T) {
+	athens, err := DefaultTariff.InTimeZone("Europe/Athens")
+	if err != nil {
+		t.Fatalf("Failed to load time zone, %s", err)
+	}
+	day, night := dayFarePerKm, nightFarePerKm
+	type args struct {
+		tariff Tariff
+		start  RidePart
+		end    RidePart
+	}
+	tests := []struct {
+		name string
+		args args
+		want float64
+	}{
+		{"night to day boundary is prorated",
+			args{DefaultTariff,
+				RidePart{1, Coord3Part1, parseDatetime("2018-12-12T04:59:50Z").UnixNano()},
+				RidePart{1, Coord3Part2, parseDatetime("2018-12-12T05:02:00Z").UnixNano()}},
+			Coord3Part12Distance * (10*night + 120*day) / 130,
+		},
+		{"day to night boundary is prorated",
+			args{DefaultTariff,
+				RidePart{1, Coord3Part1, parseDatetime("2018-12-11T23:58:00Z").UnixNano()},
+				RidePart{1, Coord3Part2, parseDatetime("2018-12-12T00:01:00Z").UnixNano()}},
+			Coord3Part12Distance * (2*day + 1*night) / 3,
+		},
+		{"segment ending on the boundary is not split",
+			args{DefaultTariff,
+				RidePart{1, Coord3Part1, parseDatetime("2018-12-12T04:57:00Z").UnixNano()},
+				RidePart{1, Coord3Part2, parseDatetime("2018-12-12T05:00:00Z").UnixNano()}},
+			Coord3Part12Distance * night,
+		},
+		{"segment crossing both boundaries is split in three parts",
+			args{DefaultTariff,
+				RidePart{1, Coord1Part1, parseDatetime("2018-12-11T23:00:00Z").UnixNano()},
+				RidePart{1, Coord1Part2, parseDatetime("2018-12-12T06:00:00Z").UnixNano()}},
+			Coord1Part12Distance * (1*day + 5*night + 1*day) / 7,
+		},
+		{"saturday to sunday boundary is prorated",
+			args{scheduleTariff,
+				RidePart{1, Coord3Part1, parseDatetime("2018-12-15T23:59:00Z").UnixNano()},
+				RidePart{1, Coord3Part2, parseDatetime("2018-12-16T00:02:00Z").UnixNano()}},
+			Coord3Part12Distance * (1*1.5 + 2*2) / 3,
+		},
+		{"idle time is charged with the idle fare of each band",
+			args{scheduleTariff,
+				RidePart{1, Coord2Part1, parseDatetime("2018-12-15T22:00:00Z").UnixNano()},
+				RidePart{1, Coord2Part1, parseDatetime("2018-12-16T01:00:00Z").UnixNano()}},
+			1*10 + 1*15 + 1*20,
+		},
+		{"night hours are 4 hours long on the spring DST transition in Athens",
+			args{athens,
+				RidePart{1, Coord1Part1, parseDatetime("2018-03-24T21:00:00Z").UnixNano()},
+				RidePart{1, Coord1Part2, parseDatetime("2018-03-25T03:00:00Z").UnixNano()}},
+			Coord1Part12Distance * (1*day + 4*night + 1*day) / 6,
+		},
+		{"night hours are 6 hours long on the autumn DST transition in Athens",
+			args{athens,
+				RidePart{1, Coord1Part1, parseDatetime("2018-10-27T20:00:00Z").UnixNano()},
+				RidePart{1, Coord1Part2, parseDatetime("2018-10-28T04:00:00Z").UnixNano()}},
+			Coord1Part12Distance * (1*day + 6*night + 1*day) / 8,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			segment := RideSegment{Start: tt.args.start, End: tt.args.end}
+			if got := segment.GetFare(tt.args.tariff); !Equal(got, tt.want, 0.01) {
 				t.Errorf("RideSegment.GetFare() = %v, want %v", got, tt.want)
 			}
 		})
@@ -212,8 +374,8 @@ func TestCalculateFareForRide(t *testing.T) {
 			"single idle segment",
 			args{
 				[]RidePart{
-					{1, Coord2Part1, int32(parseDatetime("2018-12-12T11:45:00Z").Unix())},
-					{1, Coord2Part2, int32(parseDatetime("2018-12-12T12:45:00Z").Unix())},
+					{1, Coord2Part1, parseDatetime("2018-12-12T11:45:00Z").UnixNano()},
+					{1, Coord2Part2, parseDatetime("2018-12-12T12:45:00Z").UnixNano()},
 				},
 				1},
 			want{model.RideFareEstimation{RideID: 1, CostEstimation: idleFarePerHour + flagValue}, nil},
@@ -222,10 +384,10 @@ func TestCalculateFareForRide(t *testing.T) {
 			"many day segments",
 			args{
 				[]RidePart{
-					{1, Coord3Part1, int32(parseDatetime("2018-12-12T11:00:00Z").Unix())},
-					{1, Coord3Part2, int32(parseDatetime("2018-12-12T11:03:00Z").Unix())},
-					{1, Coord3Part3, int32(parseDatetime("2018-12-12T11:06:00Z").Unix())},
-					{1, Coord3Part4, int32(parseDatetime("2018-12-12T11:14:00Z").Unix())},
+					{1, Coord3Part1, parseDatetime("2018-12-12T11:00:00Z").UnixNano()},
+					{1, Coord3Part2, parseDatetime("2018-12-12T11:03:00Z").UnixNano()},
+					{1, Coord3Part3, parseDatetime("2018-12-12T11:06:00Z").UnixNano()},
+					{1, Coord3Part4, parseDatetime("2018-12-12T11:14:00Z").UnixNano()},
 				},
 				1},
 			want{model.RideFareEstimation{RideID: 1, CostEstimation: (Coord3TotalDistance * dayFarePerKm) + flagValue}, nil},
@@ -234,10 +396,10 @@ func TestCalculateFareForRide(t *testing.T) {
 			"many night segments",
 			args{
 				[]RidePart{
-					{1, Coord3Part1, int32(parseDatetime("2018-12-12T03:00:00Z").Unix())},
-					{1, Coord3Part2, int32(parseDatetime("2018-12-12T03:03:00Z").Unix())},
-					{1, Coord3Part3, int32(parseDatetime("2018-12-12T03:06:00Z").Unix())},
-					{1, Coord3Part4, int32(parseDatetime("2018-12-12T03:14:00Z").Unix())},
+					{1, Coord3Part1, parseDatetime("2018-12-12T03:00:00Z").UnixNano()},
+					{1, Coord3Part2, parseDatetime("2018-12-12T03:03:00Z").UnixNano()},
+					{1, Coord3Part3, parseDatetime("2018-12-12T03:06:00Z").UnixNano()},
+					{1, Coord3Part4, parseDatetime("2018-12-12T03:14:00Z").UnixNano()},
 				},
 				1},
 			want{model.RideFareEstimation{RideID: 1, CostEstimation: (Coord3TotalDistance * nightFarePerKm) + flagValue}, nil},
@@ -246,10 +408,10 @@ func TestCalculateFareForRide(t *testing.T) {
 			"many idle segments",
 			args{
 				[]RidePart{
-					{1, Coord3Part1, int32(parseDatetime("2018-12-12T03:00:00Z").Unix())},
-					{1, Coord3Part2, int32(parseDatetime("2018-12-12T05:00:00Z").Unix())},
-					{1, Coord3Part3, int32(parseDatetime("2018-12-12T07:00:00Z").Unix())},
-					{1, Coord3Part4, int32(parseDatetime("2018-12-12T09:00:00Z").Unix())},
+					{1, Coord3Part1, parseDatetime("2018-12-12T03:00:00Z").UnixNano()},
+					{1, Coord3Part2, parseDatetime("2018-12-12T05:00:00Z").UnixNano()},
+					{1, Coord3Part3, parseDatetime("2018-12-12T07:00:00Z").UnixNano()},
+					{1, Coord3Part4, parseDatetime("2018-12-12T09:00:00Z").UnixNano()},
 				},
 				1},
 			want{model.RideFareEstimation{RideID: 1, CostEstimation: (6 * idleFarePerHour) + flagValue}, nil},
@@ -258,26 +420,58 @@ func TestCalculateFareForRide(t *testing.T) {
 			"combination of all day,night,idle segments",
 			args{
 				[]RidePart{
-					{1, Coord3Part1, int32(parseDatetime("2018-12-12T04:58:00Z").Unix())},
-					{1, Coord3Part2, int32(parseDatetime("2018-12-12T05:01:00Z").Unix())},
-					{1, Coord3Part3, int32(parseDatetime("2018-12-12T05:03:00Z").Unix())},
-					{1, Coord3Part4, int32(parseDatetime("2018-12-12T07:03:00Z").Unix())},
+					{1, Coord3Part1, parseDatetime("2018-12-12T04:58:00Z").UnixNano()},
+					{1, Coord3Part2, parseDatetime("2018-12-12T05:01:00Z").UnixNano()},
+					{1, Coord3Part3, parseDatetime("2018-12-12T05:03:00Z").UnixNano()},
+					{1, Coord3Part4, parseDatetime("2018-12-12T07:03:00Z").UnixNano()},
 				},
 				1},
-			want{model.RideFareEstimation{RideID: 1, CostEstimation: (Coord3Part12Distance * nightFarePerKm) +
+			want{model.RideFareEstimation{RideID: 1, CostEstimation: (Coord3Part12Distance * (2*nightFarePerKm + dayFarePerKm) / 3) +
 				(Coord3Part23Distance * dayFarePerKm) +
 				(2 * idleFarePerHour) +
 				flagValue}, nil},
 		},
+		{
+			"segments after 2038 with a 64-bit ride id",
+			args{
+				[]RidePart{
+					{9223372036854775807, Coord3Part1, parseDatetime("2040-12-12T11:00:00Z").UnixNano()},
+					{9223372036854775807, Coord3Part2, parseDatetime("2040-12-12T11:03:00Z").UnixNano()},
+				},
+				9223372036854775807},
+			want{model.RideFareEstimation{RideID: 9223372036854775807, CostEstimation: (Coord3Part12Distance * dayFarePerKm) + flagValue}, nil},
+		},
 		{
 			"no segments returns an error",
 			args{[]RidePart{}, 1},
 			want{model.RideFareEstimation{}, errNotEnoughSegments},
 		},
+		{
+			"decreasing timestamps return an error",
+			args{
+				[]RidePart{
+					{1, Coord3Part1, parseDatetime("2018-12-12T11:03:00Z").UnixNano()},
+					{1, Coord3Part2, parseDatetime("2018-12-12T11:00:00Z").UnixNano()},
+					{1, Coord3Part3, parseDatetime("2018-12-12T11:01:00Z").UnixNano()},
+				},
+				1},
+			want{model.RideFareEstimation{}, errInvalidTimestamp},
+		},
+		{
+			"decreasing timestamps and GPS anomalies return an error",
+			args{
+				[]RidePart{
+					{1, Coord3Part1, parseDatetime("2018-12-12T11:03:00Z").UnixNano()},
+					{1, Coord3Part2, parseDatetime("2018-12-12T11:00:00Z").UnixNano()},
+					{1, Coord3Part4, parseDatetime("2018-12-12T11:03:00Z").UnixNano()},
+				},
+				1},
+			want{model.RideFareEstimation{}, errNotEnoughSegments},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := CalculateFareForRide(tt.args.entries)
+			got, err := CalculateFareForRide(tt.args.entries, DefaultTariff)
 			if err != tt.want.err || got.RideID != tt.want.res.RideID || !Equal(got.CostEstimation, tt.want.res.CostEstimation, 2) {
 				t.Errorf("CalculateFareForRide() = %v,%v want %v", got, err, tt.want)
 			}
@@ -285,6 +479,96 @@ func TestCalculateFareForRide(t *testing.T) {
 	}
 }
 
+func TestCalculateFareForRide_breakdown(t *testing.T) {
+	type want struct {
+		res model.FareBreakdown
+		err error
+	}
+	tests := []struct {
+		name    string
+		entries []RidePart
+		want    want
+	}{
+		{
+			"combination of all day,night,idle segments",
+			[]RidePart{
+				{1, Coord3Part1, parseDatetime("2018-12-12T04:58:00Z").UnixNano()},
+				{1, Coord3Part2, parseDatetime("2018-12-12T05:01:00Z").UnixNano()},
+				{1, Coord3Part3, parseDatetime("2018-12-12T05:03:00Z").UnixNano()},
+				{1, Coord3Part4, parseDatetime("2018-12-12T07:03:00Z").UnixNano()},
+			},
+			want{model.FareBreakdown{
+				Flag: flagValue,
+				Bands: []model.BandCharge{
+					{Name: "night", MovingKm: Coord3Part12Distance * 2 / 3, MovingCost: Coord3Part12Distance * 2 / 3 * nightFarePerKm},
+					{Name: "day", MovingKm: Coord3Part12Distance/3 + Coord3Part23Distance, MovingCost: (Coord3Part12Distance/3 + Coord3Part23Distance) * dayFarePerKm},
+				},
+				IdleHours: 2,
+				IdleCost:  2 * idleFarePerHour,
+			}, nil},
+		},
+		{
+			"discarded points and minimum top up",
+			[]RidePart{
+				{1, Coord2Part1, parseDatetime("2018-12-12T11:00:00Z").UnixNano()},
+				{1, Coord1Part2, parseDatetime("2018-12-12T11:00:01Z").UnixNano()},
+				{1, Coord2Part2, parseDatetime("2018-12-12T11:03:00Z").UnixNano()},
+			},
+			want{model.FareBreakdown{
+				Flag: flagValue,
+				Bands: []model.BandCharge{
+					{Name: "night"},
+					{Name: "day", MovingKm: Coord2Part12Distance, MovingCost: Coord2Part12Distance * dayFarePerKm},
+				},
+				MinimumTopUp:    3.47 - flagValue - Coord2Part12Distance*dayFarePerKm,
+				DiscardedPoints: 1,
+			}, nil},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := CalculateFareForRide(tt.entries, DefaultTariff)
+			if err != tt.want.err || !equalBreakdown(got.Breakdown, tt.want.res, 0.01) {
+				t.Errorf("CalculateFareForRide() breakdown = %v,%v want %v", got.Breakdown, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestFailureKind(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"not enough segments", errNotEnoughSegments, "not_enough_segments"},
+		{"wrapped invalid timestamp", fmt.Errorf("segment 2: %w", errInvalidTimestamp), "end_timestamp_not_greater_than_start"},
+		{"unknown error", errors.New("unknown"), "calculation_failed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := FailureKind(tt.err); got != tt.want {
+				t.Errorf("FailureKind() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func equalBreakdown(x, y model.FareBreakdown, tolerance float64) bool {
+	if len(x.Bands) != len(y.Bands) {
+		return false
+	}
+	for i := range x.Bands {
+		if x.Bands[i].Name != y.Bands[i].Name || !Equal(x.Bands[i].MovingKm, y.Bands[i].MovingKm, tolerance) ||
+			!Equal(x.Bands[i].MovingCost, y.Bands[i].MovingCost, tolerance) {
+			return false
+		}
+	}
+	return Equal(x.Flag, y.Flag, tolerance) && Equal(x.IdleHours, y.IdleHours, tolerance) &&
+		Equal(x.IdleCost, y.IdleCost, tolerance) && Equal(x.MinimumTopUp, y.MinimumTopUp, tolerance) &&
+		x.DiscardedPoints == y.DiscardedPoints
+}
+
 func parseDatetime(str string) time.Time {
 	t, err := time.Parse(time.RFC3339, str)
 
@@ -296,5 +580,5 @@ func parseDatetime(str string) time.Time {
 }
 
 func Equal(x, y, tolerance float64) bool {
-	return math.Abs(x-y) < tolerance
+	return x == y || math.Abs(x-y) < tolerance
 }
@@ -0,0 +1,103 @@
+package calculator
+
+import (
+	"container/heap"
+	"sort"
+)
+
+// ReorderParts sorts the parts of a ride by timestamp, keeping the order of parts with the same timestamp, and returns
+// them with the number of parts that were moved before a part preceding them in the input.
+// When window is 0 the whole ride is sorted. Otherwise the parts are sorted as a stream, through a buffer of window
+// parts, so that a part is only moved if it is at most window parts late, and is otherwise left in place, to be
+// discarded by GetValidSegments
+func ReorderParts(entries []RidePart, window int) ([]RidePart, int) {
+	if window <= 0 {
+		return sortParts(entries)
+	}
+
+	result := make([]RidePart, 0, len(entries))
+	buffer := make(reorderBuffer, 0, window+1)
+	reordered := 0
+
+	var latest int64
+
+	emit := func() {
+		item := heap.Pop(&buffer).(reorderItem)
+		if item.late && (len(result) == 0 || item.part.Timestamp >= result[len(result)-1].Timestamp) {
+			reordered++
+		}
+		result = append(result, item.part)
+	}
+
+	for i, entry := range entries {
+		heap.Push(&buffer, reorderItem{part: entry, index: i, late: i > 0 && entry.Timestamp < latest})
+		if i == 0 || entry.Timestamp > latest {
+			latest = entry.Timestamp
+		}
+
+		if buffer.Len() > window {
+			emit()
+		}
+	}
+
+	for buffer.Len() > 0 {
+		emit()
+	}
+
+	return result, reordered
+}
+
+func sortParts(entries []RidePart) ([]RidePart, int) {
+	reordered := 0
+
+	var latest int64
+	for i, entry := range entries {
+		if i > 0 && entry.Timestamp < latest {
+			reordered++
+		}
+		if i == 0 || entry.Timestamp > latest {
+			latest = entry.Timestamp
+		}
+	}
+
+	if reordered == 0 {
+		return entries, 0
+	}
+
+	result := append([]RidePart(nil), entries...)
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Timestamp < result[j].Timestamp
+	})
+
+	return result, reordered
+}
+
+// reorderItem is a buffered part, with its position in the input, and whether a later part preceded it
+type reorderItem struct {
+	part  RidePart
+	index int
+	late  bool
+}
+
+// reorderBuffer is a min-heap of the buffered parts, by timestamp and then by position in the input
+type reorderBuffer []reorderItem
+
+func (buffer reorderBuffer) Len() int { return len(buffer) }
+
+func (buffer reorderBuffer) Less(i, j int) bool {
+	if buffer[i].part.Timestamp != buffer[j].part.Timestamp {
+		return buffer[i].part.Timestamp < buffer[j].part.Timestamp
+	}
+	return buffer[i].index < buffer[j].index
+}
+
+func (buffer reorderBuffer) Swap(i, j int) { buffer[i], buffer[j] = buffer[j], buffer[i] }
+
+func (buffer *reorderBuffer) Push(item interface{}) { *buffer = append(*buffer, item.(reorderItem)) }
+
+func (buffer *reorderBuffer) Pop() interface{} {
+	old := *buffer
+	item := old[len(old)-1]
+	*buffer = old[:len(old)-1]
+	return item
+}
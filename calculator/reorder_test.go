@@ -0,0 +1,66 @@
+package calculator
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestReorderParts(t *testing.T) {
+	// parts returns a part per timestamp, with the latitude telling apart the parts with the same timestamp
+	parts := func(timestamps ...int64) []RidePart {
+		result := make([]RidePart, 0, len(timestamps))
+		for i, timestamp := range timestamps {
+			result = append(result, RidePart{RideID: 1, Coordinate: Coordinate{Latitude: float64(i)}, Timestamp: timestamp * 1e9})
+		}
+		return result
+	}
+	type args struct {
+		entries []RidePart
+		window  int
+	}
+	type want struct {
+		timestamps []int64
+		reordered  int
+	}
+	tests := []struct {
+		name string
+		args args
+		want want
+	}{
+		{"sorted ride", args{parts(1, 2, 3), 0}, want{[]int64{1, 2, 3}, 0}},
+		{"whole ride", args{parts(1, 3, 2, 4, 0), 0}, want{[]int64{0, 1, 2, 3, 4}, 2}},
+		{"window", args{parts(1, 3, 2, 4), 1}, want{[]int64{1, 2, 3, 4}, 1}},
+		{"part later than the window is left in place", args{parts(1, 3, 4, 2, 5), 1}, want{[]int64{1, 3, 2, 4, 5}, 0}},
+		{"part within a larger window", args{parts(1, 3, 4, 2, 5), 2}, want{[]int64{1, 2, 3, 4, 5}, 1}},
+		{"empty ride", args{nil, 3}, want{[]int64{}, 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, reordered := ReorderParts(tt.args.entries, tt.args.window)
+
+			timestamps := make([]int64, 0, len(got))
+			for _, part := range got {
+				timestamps = append(timestamps, part.Timestamp/1e9)
+			}
+
+			if !reflect.DeepEqual(timestamps, tt.want.timestamps) || reordered != tt.want.reordered {
+				t.Errorf("ReorderParts() = %v, %v, want %v, %v", timestamps, reordered, tt.want.timestamps, tt.want.reordered)
+			}
+		})
+	}
+}
+
+func TestReorderParts_stable(t *testing.T) {
+	entries := []RidePart{
+		{RideID: 1, Coordinate: Coordinate{Latitude: 1}, Timestamp: 20},
+		{RideID: 1, Coordinate: Coordinate{Latitude: 2}, Timestamp: 10},
+		{RideID: 1, Coordinate: Coordinate{Latitude: 3}, Timestamp: 20},
+	}
+	want := []RidePart{entries[1], entries[0], entries[2]}
+
+	for _, window := range []int{0, 1, 2} {
+		if got, _ := ReorderParts(entries, window); !reflect.DeepEqual(got, want) {
+			t.Errorf("ReorderParts() with window %d = %v, want %v", window, got, want)
+		}
+	}
+}
@@ -0,0 +1,232 @@
+package calculator
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	// Embeds the IANA time zone database, so that tariff time zones do not depend on the host
+	_ "time/tzdata"
+
+	"gopkg.in/yaml.v3"
+)
+
+const dateLayout = "2006-01-02"
+
+// Tariff contains the amounts used to estimate the fare of a ride: the flag and minimum amounts, and a schedule of Bands
+// evaluated in the local time of the IANA time zone of the tariff, on which the public Holidays(YYYY-MM-DD) are defined
+type Tariff struct {
+	FlagValue   float64  `json:"flag_value" yaml:"flag_value"`
+	MinimumRide float64  `json:"minimum_ride" yaml:"minimum_ride"`
+	TimeZone    string   `json:"time_zone" yaml:"time_zone"`
+	Bands       []Band   `json:"bands" yaml:"bands"`
+	Holidays    []string `json:"holidays" yaml:"holidays"`
+
+	location *time.Location
+}
+
+// DefaultTariff is the tariff used when no tariff file is given
+var DefaultTariff = Tariff{
+	FlagValue:   1.30,
+	MinimumRide: 3.47,
+	TimeZone:    "UTC",
+	Bands: []Band{
+		{Name: "night", From: 0, To: 5 * 60, FarePerKm: 1.30, IdleFarePerHour: 11.90},
+		{Name: "day", From: 5 * 60, To: 24 * 60, FarePerKm: 0.74, IdleFarePerHour: 11.90},
+	},
+}
+
+// LoadTariff reads a JSON(.json) or YAML(.yaml, .yml) tariff file, and validates it.
+// Fields missing from the file keep the value of DefaultTariff, unknown fields are rejected
+func LoadTariff(path string) (Tariff, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return Tariff{}, err
+	}
+
+	// the slices are decoded into fresh ones, so that the ones of DefaultTariff are never written to
+	tariff := DefaultTariff
+	tariff.Bands = nil
+	tariff.Holidays = nil
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		decoder := json.NewDecoder(bytes.NewReader(content))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(&tariff)
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(bytes.NewReader(content))
+		decoder.KnownFields(true)
+		err = decoder.Decode(&tariff)
+	default:
+		return Tariff{}, fmt.Errorf("unsupported tariff file extension %q, expected .json, .yaml or .yml", filepath.Ext(path))
+	}
+
+	if err != nil {
+		return Tariff{}, fmt.Errorf("cannot parse tariff file %s: %w", path, err)
+	}
+
+	if tariff.Bands == nil {
+		tariff.Bands = append([]Band(nil), DefaultTariff.Bands...)
+	}
+
+	if err = tariff.Validate(); err != nil {
+		return Tariff{}, fmt.Errorf("invalid tariff file %s: %w", path, err)
+	}
+
+	if tariff, err = tariff.InTimeZone(tariff.TimeZone); err != nil {
+		return Tariff{}, fmt.Errorf("invalid tariff file %s: %w", path, err)
+	}
+
+	return tariff, nil
+}
+
+// InTimeZone returns a copy of the tariff, whose bands are evaluated in the given IANA time zone(e.g. Europe/Athens)
+// An empty name is interpreted as UTC
+func (tariff Tariff) InTimeZone(name string) (Tariff, error) {
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		return Tariff{}, fmt.Errorf("unknown time_zone %q: %w", name, err)
+	}
+
+	tariff.TimeZone = name
+	tariff.location = location
+
+	return tariff, nil
+}
+
+// Location returns the time zone of the tariff, defaulting to UTC
+func (tariff Tariff) Location() *time.Location {
+	if tariff.location == nil {
+		return time.UTC
+	}
+	return tariff.location
+}
+
+// Validate returns an error if:
+//   - any of the amounts of the tariff is negative, NaN or infinite
+//   - a band has no name, a name used by another band, or an unknown day
+//   - a holiday is not a YYYY-MM-DD date
+//   - there is a time of the week, on a holiday or not, to which no band applies
+func (tariff Tariff) Validate() error {
+	type amount struct {
+		name  string
+		value float64
+	}
+	amounts := []amount{
+		{"flag_value", tariff.FlagValue},
+		{"minimum_ride", tariff.MinimumRide},
+	}
+	for _, band := range tariff.Bands {
+		amounts = append(amounts,
+			amount{fmt.Sprintf("bands[%s].fare_per_km", band.Name), band.FarePerKm},
+			amount{fmt.Sprintf("bands[%s].idle_fare_per_hour", band.Name), band.IdleFarePerHour},
+		)
+	}
+
+	for _, amount := range amounts {
+		if math.IsNaN(amount.value) || math.IsInf(amount.value, 0) || amount.value < 0 {
+			return fmt.Errorf("%s must be a non-negative number, got %v", amount.name, amount.value)
+		}
+	}
+
+	names := make(map[string]bool, len(tariff.Bands))
+	for i, band := range tariff.Bands {
+		if band.Name == "" {
+			return fmt.Errorf("bands[%d] has no name", i)
+		}
+		if names[band.Name] {
+			return fmt.Errorf("band name %q is used more than once", band.Name)
+		}
+		names[band.Name] = true
+
+		for _, day := range band.Days {
+			if _, known := dayNames[day]; !known {
+				return fmt.Errorf("bands[%s] has unknown day %q, expected one of mon-sun, weekday, weekend or holiday", band.Name, day)
+			}
+		}
+	}
+
+	for _, holiday := range tariff.Holidays {
+		if _, err := time.Parse(dateLayout, holiday); err != nil {
+			return fmt.Errorf("holiday %q is not a YYYY-MM-DD date", holiday)
+		}
+	}
+
+	return tariff.validateCoverage()
+}
+
+// validateCoverage checks that a band applies to every minute of every day of the week, both on holidays and not
+func (tariff Tariff) validateCoverage() error {
+	for _, isHoliday := range []bool{false, true} {
+		for weekday := time.Sunday; weekday <= time.Saturday; weekday++ {
+			for minute := ClockTime(0); minute < minutesPerDay; minute++ {
+				if _, found := tariff.band(weekday, isHoliday, minute); !found {
+					description := weekday.String()
+					if isHoliday {
+						description += " holiday"
+					}
+					return fmt.Errorf("no band applies on %s at %s", description, minute)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// bandAt returns the index of the first band of the tariff that applies to the given time, in the time zone of the tariff,
+// or -1 if no band applies
+func (tariff Tariff) bandAt(ts time.Time) int {
+	ts = ts.In(tariff.Location())
+
+	index, _ := tariff.band(ts.Weekday(), tariff.isHoliday(ts), ClockTime(ts.Hour()*60+ts.Minute()))
+
+	return index
+}
+
+func (tariff Tariff) band(weekday time.Weekday, isHoliday bool, minute ClockTime) (int, bool) {
+	for i, band := range tariff.Bands {
+		if band.appliesOn(weekday, isHoliday) && band.contains(minute) {
+			return i, true
+		}
+	}
+	return -1, false
+}
+
+func (tariff Tariff) isHoliday(ts time.Time) bool {
+	if len(tariff.Holidays) == 0 {
+		return false
+	}
+
+	date := ts.Format(dateLayout)
+	for _, holiday := range tariff.Holidays {
+		if holiday == date {
+			return true
+		}
+	}
+	return false
+}
+
+// nextBoundary returns the first time after the given one, on which a different band may apply:
+// the start or the end of a band, or the next midnight, in the time zone of the tariff
+func (tariff Tariff) nextBoundary(ts time.Time) time.Time {
+	ts = ts.In(tariff.Location())
+	year, month, day := ts.Date()
+
+	next := time.Date(year, month, day+1, 0, 0, 0, 0, ts.Location())
+
+	for _, band := range tariff.Bands {
+		for _, edge := range []ClockTime{band.From, band.To} {
+			candidate := time.Date(year, month, day, 0, int(edge), 0, 0, ts.Location())
+			if candidate.After(ts) && candidate.Before(next) {
+				next = candidate
+			}
+		}
+	}
+
+	return next
+}
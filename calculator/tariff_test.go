@@ -0,0 +1,208 @@
+package calculator
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestLoadTariff(t *testing.T) {
+	type args struct {
+		fileName string
+		content  string
+	}
+	type want struct {
+		res     Tariff
+		wantErr bool
+	}
+	tests := []struct {
+		name string
+		args args
+		want want
+	}{
+		{
+			"json tariff",
+			args{"tariff.json", `{"flag_value": 4, "minimum_ride": 5, "holidays": ["2018-12-25"], "bands": [
+				{"name": "sunday", "days": ["sun", "holiday"], "fare_per_km": 3, "idle_fare_per_hour": 12},
+				{"name": "all", "from": "00:00", "to": "24:00", "fare_per_km": 1, "idle_fare_per_hour": 10}]}`},
+			want{Tariff{FlagValue: 4, MinimumRide: 5, TimeZone: "UTC", Holidays: []string{"2018-12-25"}, Bands: []Band{
+				{Name: "sunday", Days: []string{"sun", "holiday"}, FarePerKm: 3, IdleFarePerHour: 12},
+				{Name: "all", From: 0, To: 24 * 60, FarePerKm: 1, IdleFarePerHour: 10},
+			}, location: time.UTC}, false},
+		},
+		{
+			"yaml tariff",
+			args{"tariff.yaml", `flag_value: 4
+minimum_ride: 5
+bands:
+  - {name: night, from: "22:30", to: "06:00", fare_per_km: 2, idle_fare_per_hour: 10}
+  - {name: day, from: "06:00", to: "22:30", fare_per_km: 1, idle_fare_per_hour: 10}
+`},
+			want{Tariff{FlagValue: 4, MinimumRide: 5, TimeZone: "UTC", Bands: []Band{
+				{Name: "night", From: 22*60 + 30, To: 6 * 60, FarePerKm: 2, IdleFarePerHour: 10},
+				{Name: "day", From: 6 * 60, To: 22*60 + 30, FarePerKm: 1, IdleFarePerHour: 10},
+			}, location: time.UTC}, false},
+		},
+		{
+			"missing fields keep the default values",
+			args{"tariff.yml", "flag_value: 2.5\n"},
+			want{Tariff{FlagValue: 2.5, MinimumRide: 3.47, TimeZone: "UTC", Bands: DefaultTariff.Bands, location: time.UTC}, false},
+		},
+		{
+			"unknown time zone returns an error",
+			args{"tariff.yaml", "time_zone: Europe/Atlantis\n"},
+			want{Tariff{}, true},
+		},
+		{
+			"unknown fields return an error",
+			args{"tariff.json", `{"flag_vaule": 2.5}`},
+			want{Tariff{}, true},
+		},
+		{
+			"negative amounts return an error",
+			args{"tariff.yaml", "minimum_ride: -1\n"},
+			want{Tariff{}, true},
+		},
+		{
+			"negative band fares return an error",
+			args{"tariff.yaml", "bands: [{name: all, fare_per_km: -1, idle_fare_per_hour: 10}]\n"},
+			want{Tariff{}, true},
+		},
+		{
+			"invalid time of day returns an error",
+			args{"tariff.yaml", "bands: [{name: all, from: \"25:00\", to: \"05:00\", fare_per_km: 1, idle_fare_per_hour: 10}]\n"},
+			want{Tariff{}, true},
+		},
+		{
+			"unknown day returns an error",
+			args{"tariff.yaml", "bands: [{name: all, days: [someday], fare_per_km: 1, idle_fare_per_hour: 10}]\n"},
+			want{Tariff{}, true},
+		},
+		{
+			"duplicate band names return an error",
+			args{"tariff.yaml", "bands: [{name: all, fare_per_km: 1}, {name: all, fare_per_km: 2}]\n"},
+			want{Tariff{}, true},
+		},
+		{
+			"invalid holiday returns an error",
+			args{"tariff.yaml", "holidays: [25/12/2018]\n"},
+			want{Tariff{}, true},
+		},
+		{
+			"uncovered time of the week returns an error",
+			args{"tariff.yaml", "bands: [{name: weekday, days: [weekday], fare_per_km: 1, idle_fare_per_hour: 10}]\n"},
+			want{Tariff{}, true},
+		},
+		{
+			"malformed file returns an error",
+			args{"tariff.json", `{"flag_value": `},
+			want{Tariff{}, true},
+		},
+		{
+			"unsupported extension returns an error",
+			args{"tariff.toml", "flag_value = 2.5\n"},
+			want{Tariff{}, true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tt.args.fileName)
+			if err := os.WriteFile(path, []byte(tt.args.content), 0o600); err != nil {
+				t.Fatalf("Failed to write tariff file, %s", err)
+			}
+
+			got, err := LoadTariff(path)
+			if (err != nil) != tt.want.wantErr || !reflect.DeepEqual(got, tt.want.res) {
+				t.Errorf("LoadTariff() = %v, %v, want %v, error: %v", got, err, tt.want.res, tt.want.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoadTariff_missingFile(t *testing.T) {
+	if _, err := LoadTariff(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Errorf("LoadTariff() did not return an error for a missing file")
+	}
+}
+
+func TestTariff_InTimeZone(t *testing.T) {
+	tests := []struct {
+		name     string
+		timeZone string
+		want     string
+		wantErr  bool
+	}{
+		{"IANA time zone", "Europe/Athens", "Europe/Athens", false},
+		{"empty time zone is UTC", "", "UTC", false},
+		{"unknown time zone returns an error", "Mars/Olympus_Mons", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DefaultTariff.InTimeZone(tt.timeZone)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Tariff.InTimeZone() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && got.Location().String() != tt.want {
+				t.Errorf("Tariff.InTimeZone() location = %v, want %v", got.Location(), tt.want)
+			}
+		})
+	}
+}
+
+var scheduleTariff = Tariff{
+	FlagValue:   1,
+	MinimumRide: 2,
+	Holidays:    []string{"2018-12-25"},
+	Bands: []Band{
+		{Name: "holiday", Days: []string{"holiday"}, FarePerKm: 3, IdleFarePerHour: 30},
+		{Name: "sunday", Days: []string{"sun"}, FarePerKm: 2, IdleFarePerHour: 20},
+		{Name: "weekend-night", Days: []string{"weekend"}, From: 23 * 60, To: 6 * 60, FarePerKm: 1.5, IdleFarePerHour: 15},
+		{Name: "regular", FarePerKm: 1, IdleFarePerHour: 10},
+	},
+}
+
+func TestTariff_bandAt(t *testing.T) {
+	tests := []struct {
+		name string
+		ts   string
+		want string
+	}{
+		{"weekday", "2018-12-12T12:00:00Z", "regular"},
+		{"holiday on a weekday", "2018-12-25T12:00:00Z", "holiday"},
+		{"sunday", "2018-12-16T12:00:00Z", "sunday"},
+		{"saturday night before midnight", "2018-12-15T23:30:00Z", "weekend-night"},
+		{"saturday night after midnight", "2018-12-15T03:00:00Z", "weekend-night"},
+		{"saturday day", "2018-12-15T12:00:00Z", "regular"},
+		{"band end is exclusive", "2018-12-15T06:00:00Z", "regular"},
+		{"band start is inclusive", "2018-12-15T23:00:00Z", "weekend-night"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := scheduleTariff.bandAt(parseDatetime(tt.ts)); scheduleTariff.Bands[got].Name != tt.want {
+				t.Errorf("Tariff.bandAt() = %v, want %v", scheduleTariff.Bands[got].Name, tt.want)
+			}
+		})
+	}
+}
+
+func TestTariff_nextBoundary(t *testing.T) {
+	tests := []struct {
+		name string
+		ts   string
+		want string
+	}{
+		{"next band start", "2018-12-15T12:00:00Z", "2018-12-15T23:00:00Z"},
+		{"next band end", "2018-12-15T03:00:00Z", "2018-12-15T06:00:00Z"},
+		{"next midnight", "2018-12-15T23:00:00Z", "2018-12-16T00:00:00Z"},
+		{"a boundary is not returned for itself", "2018-12-15T06:00:00Z", "2018-12-15T23:00:00Z"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := scheduleTariff.nextBoundary(parseDatetime(tt.ts)); !got.Equal(parseDatetime(tt.want)) {
+				t.Errorf("Tariff.nextBoundary() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,205 @@
+package calculator
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// The reasons a point of a ride is rejected
+const (
+	// RejectedOverSpeed is the reason of a point reached from the previous valid point at over 100 km/h
+	RejectedOverSpeed = "over_100_kmh"
+	// RejectedTimestamp is the reason of a point timestamped before the previous valid point
+	RejectedTimestamp = "end_timestamp_not_greater_than_start"
+)
+
+// The states of a segment
+const (
+	StateMoving = "moving"
+	StateIdle   = "idle"
+)
+
+// Speed is a speed in km/h, which is infinite between two distinct points with the same timestamp, and is written in
+// JSON as null then
+type Speed float64
+
+// MarshalJSON writes the speed as a JSON number, or null when it is infinite
+func (speed Speed) MarshalJSON() ([]byte, error) {
+	if math.IsInf(float64(speed), 0) {
+		return []byte("null"), nil
+	}
+	return json.Marshal(float64(speed))
+}
+
+// PointTrace is a point of a ride, and whether it is kept by GetValidSegments
+type PointTrace struct {
+	Index     int       `json:"index"`
+	Latitude  float64   `json:"lat"`
+	Longitude float64   `json:"lng"`
+	Timestamp time.Time `json:"timestamp"`
+	Kept      bool      `json:"kept"`
+	// Speed is the speed from the previous kept point, 0 for the first point, and for a point rejected for its timestamp
+	Speed Speed `json:"speed_kmh"`
+	// Reason is the reason the point is rejected, empty when it is kept
+	Reason string `json:"reason,omitempty"`
+}
+
+// ChargeTrace is the part of a segment during which a single band applies, with its fare
+type ChargeTrace struct {
+	// Band is the name of the band applied, empty when no band applies
+	Band  string  `json:"band"`
+	Km    float64 `json:"km"`
+	Hours float64 `json:"hours"`
+	Fare  float64 `json:"fare"`
+}
+
+// SegmentTrace is a valid segment of a ride, between the points at indexes From and To, with its fare, which is split
+// into a ChargeTrace per band the segment crosses
+type SegmentTrace struct {
+	From    int           `json:"from"`
+	To      int           `json:"to"`
+	Km      float64       `json:"km"`
+	Hours   float64       `json:"hours"`
+	Speed   Speed         `json:"speed_kmh"`
+	State   string        `json:"state"`
+	Charges []ChargeTrace `json:"charges"`
+	Fare    float64       `json:"fare"`
+}
+
+// RideTrace explains how the fare of a ride is calculated by CalculateFareForRide: which points are kept, and how each
+// valid segment is charged
+type RideTrace struct {
+	RideID   int64          `json:"id_ride"`
+	Points   []PointTrace   `json:"points"`
+	Segments []SegmentTrace `json:"segments"`
+	Flag     float64        `json:"flag"`
+	// MinimumTopUp is the amount added to reach Tariff.MinimumRide
+	MinimumTopUp float64 `json:"minimum_top_up"`
+	FareEstimate float64 `json:"fare_estimate"`
+	// Failure is the kind of error of a ride whose fare cannot be calculated, as returned by FailureKind
+	Failure string `json:"failure,omitempty"`
+}
+
+// TraceRide returns the trace of the fare of the ride using the given Tariff, with the same filtering as
+// GetValidSegments, and the same fare as CalculateFareForRide
+func TraceRide(entries []RidePart, tariff Tariff) RideTrace {
+	trace := RideTrace{Points: make([]PointTrace, len(entries)), Segments: make([]SegmentTrace, 0, len(entries))}
+	if len(entries) > 0 {
+		trace.RideID = entries[0].RideID
+	}
+
+	for i, entry := range entries {
+		trace.Points[i] = PointTrace{Index: i, Latitude: entry.Coordinate.Latitude, Longitude: entry.Coordinate.Longitude,
+			Timestamp: time.Unix(0, entry.Timestamp).In(tariff.Location()), Kept: i == 0}
+	}
+
+	for _, check := range filterSegments(entries) {
+		point := &trace.Points[check.end]
+		point.Speed = Speed(check.kmPerHour)
+
+		switch {
+		case check.err != nil:
+			point.Reason = RejectedTimestamp
+		case !check.valid:
+			point.Reason = RejectedOverSpeed
+		default:
+			point.Kept = true
+			trace.Segments = append(trace.Segments, traceSegment(check, RideSegment{Start: entries[check.start], End: entries[check.end]}, tariff))
+		}
+	}
+
+	estimation, err := CalculateFareForRide(entries, tariff)
+	if err != nil {
+		trace.Failure = FailureKind(err)
+		return trace
+	}
+
+	trace.Flag = estimation.Breakdown.Flag
+	trace.MinimumTopUp = estimation.Breakdown.MinimumTopUp
+	trace.FareEstimate = estimation.CostEstimation
+	return trace
+}
+
+// traceSegment returns the trace of the valid segment of the check
+func traceSegment(check segmentCheck, segment RideSegment, tariff Tariff) SegmentTrace {
+	result := SegmentTrace{
+		From:  check.start,
+		To:    check.end,
+		Km:    HarvestineInKilometers(segment.Start.Coordinate, segment.End.Coordinate),
+		Hours: time.Duration(segment.End.Timestamp - segment.Start.Timestamp).Hours(),
+		Speed: Speed(check.kmPerHour),
+		State: StateMoving,
+	}
+
+	for _, charge := range segment.charges(tariff) {
+		if charge.idle {
+			result.State = StateIdle
+		}
+
+		chargeTrace := ChargeTrace{Km: charge.km, Hours: charge.hours, Fare: charge.cost}
+		if charge.band >= 0 {
+			chargeTrace.Band = tariff.Bands[charge.band].Name
+		}
+		result.Charges = append(result.Charges, chargeTrace)
+		result.Fare += charge.cost
+	}
+
+	return result
+}
+
+// WriteJSON writes the trace into the writer as an indented JSON object
+func (trace RideTrace) WriteJSON(writer io.Writer) error {
+	encoder := json.NewEncoder(writer)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(trace)
+}
+
+// WriteTable writes the trace into the writer as a table of the points, followed by a table of the valid segments, and
+// the amounts added to the fare
+func (trace RideTrace) WriteTable(writer io.Writer) error {
+	fmt.Fprintf(writer, "Ride %d: %d points, %d valid segments\n\n", trace.RideID, len(trace.Points), len(trace.Segments))
+
+	table := tabwriter.NewWriter(writer, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(table, "point\ttimestamp\tlat\tlng\tkm/h\tkept\treason\t")
+	for _, point := range trace.Points {
+		fmt.Fprintf(table, "%d\t%s\t%.6f\t%.6f\t%s\t%v\t%s\t\n", point.Index, point.Timestamp.Format(time.RFC3339),
+			point.Latitude, point.Longitude, formatSpeed(point.Speed), point.Kept, point.Reason)
+	}
+	fmt.Fprintln(table)
+
+	fmt.Fprintln(table, "segment\tpoints\tkm\thours\tkm/h\tstate\tband\tfare\t")
+	for i, segment := range trace.Segments {
+		bands := make([]string, 0, len(segment.Charges))
+		for _, charge := range segment.Charges {
+			bands = append(bands, charge.Band)
+		}
+
+		fmt.Fprintf(table, "%d\t%d-%d\t%.3f\t%.4f\t%s\t%s\t%s\t%.2f\t\n", i+1, segment.From, segment.To, segment.Km,
+			segment.Hours, formatSpeed(segment.Speed), segment.State, strings.Join(bands, "+"), segment.Fare)
+	}
+	if err := table.Flush(); err != nil {
+		return err
+	}
+
+	if trace.Failure != "" {
+		_, err := fmt.Fprintf(writer, "\nFailed: %s\n", trace.Failure)
+		return err
+	}
+
+	_, err := fmt.Fprintf(writer, "\nFlag %.2f, minimum top-up %.2f, fare estimate %.2f\n", trace.Flag, trace.MinimumTopUp, trace.FareEstimate)
+	return err
+}
+
+// formatSpeed formats the speed with 2 decimals, or as inf
+func formatSpeed(speed Speed) string {
+	if math.IsInf(float64(speed), 0) {
+		return "inf"
+	}
+	return fmt.Sprintf("%.2f", float64(speed))
+}
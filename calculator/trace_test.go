@@ -0,0 +1,145 @@
+package calculator
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestTraceRide(t *testing.T) {
+	type wantPoint struct {
+		kept   bool
+		reason string
+	}
+	type wantSegment struct {
+		from, to int
+		state    string
+		bands    []string
+	}
+	tests := []struct {
+		name         string
+		entries      []RidePart
+		wantPoints   []wantPoint
+		wantSegments []wantSegment
+		wantFailure  string
+	}{
+		{
+			"points over 100 km/h and before the previous point are rejected",
+			[]RidePart{
+				{1, Coord3Part1, parseDatetime("2018-12-12T10:00:00Z").UnixNano()},
+				{1, Coord1Part1, parseDatetime("2018-12-12T10:01:00Z").UnixNano()},
+				{1, Coord3Part2, parseDatetime("2018-12-12T09:59:00Z").UnixNano()},
+				{1, Coord3Part2, parseDatetime("2018-12-12T10:03:00Z").UnixNano()},
+				{1, Coord3Part2, parseDatetime("2018-12-12T11:03:00Z").UnixNano()},
+			},
+			[]wantPoint{{true, ""}, {false, RejectedOverSpeed}, {false, RejectedTimestamp}, {true, ""}, {true, ""}},
+			[]wantSegment{{0, 3, StateMoving, []string{"day"}}, {3, 4, StateIdle, []string{"day"}}},
+			"",
+		},
+		{
+			"segment crossing a band boundary is charged with both bands",
+			[]RidePart{
+				{1, Coord3Part1, parseDatetime("2018-12-12T04:59:00Z").UnixNano()},
+				{1, Coord3Part2, parseDatetime("2018-12-12T05:01:00Z").UnixNano()},
+			},
+			[]wantPoint{{true, ""}, {true, ""}},
+			[]wantSegment{{0, 1, StateMoving, []string{"night", "day"}}},
+			"",
+		},
+		{
+			"ride without a valid segment fails",
+			[]RidePart{{1, Coord3Part1, parseDatetime("2018-12-12T10:00:00Z").UnixNano()}},
+			[]wantPoint{{true, ""}},
+			nil,
+			"not_enough_segments",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := TraceRide(tt.entries, DefaultTariff)
+
+			if len(got.Points) != len(tt.wantPoints) || len(got.Segments) != len(tt.wantSegments) || got.Failure != tt.wantFailure {
+				t.Fatalf("TraceRide() = %+v, want %d points, %d segments, failure %q", got, len(tt.wantPoints), len(tt.wantSegments), tt.wantFailure)
+			}
+			for i, want := range tt.wantPoints {
+				if point := got.Points[i]; point.Index != i || point.Kept != want.kept || point.Reason != want.reason {
+					t.Errorf("point %d = %+v, want kept: %v, reason %q", i, point, want.kept, want.reason)
+				}
+			}
+
+			fare := 0.0
+			for i, want := range tt.wantSegments {
+				segment := got.Segments[i]
+				bands := make([]string, 0, len(segment.Charges))
+				for _, charge := range segment.Charges {
+					bands = append(bands, charge.Band)
+				}
+				if segment.From != want.from || segment.To != want.to || segment.State != want.state || strings.Join(bands, "+") != strings.Join(want.bands, "+") {
+					t.Errorf("segment %d = %+v, want %v", i, segment, want)
+				}
+				fare += segment.Fare
+			}
+
+			// the trace adds up to the estimate of CalculateFareForRide
+			if estimation, err := CalculateFareForRide(tt.entries, DefaultTariff); err == nil {
+				if math.Abs(got.Flag+fare+got.MinimumTopUp-estimation.CostEstimation) > 1e-9 || got.FareEstimate != estimation.CostEstimation {
+					t.Errorf("TraceRide() adds up to %v, and estimates %v, want %v", got.Flag+fare+got.MinimumTopUp, got.FareEstimate, estimation.CostEstimation)
+				}
+			}
+		})
+	}
+}
+
+func TestRideTrace_WriteJSON(t *testing.T) {
+	trace := TraceRide([]RidePart{
+		{1, Coord3Part1, parseDatetime("2018-12-12T10:00:00Z").UnixNano()},
+		{1, Coord3Part2, parseDatetime("2018-12-12T10:00:00Z").UnixNano()},
+	}, DefaultTariff)
+
+	output := bytes.NewBufferString("")
+	if err := trace.WriteJSON(output); err != nil {
+		t.Fatalf("WriteJSON() returned error %v", err)
+	}
+
+	var got struct {
+		Points []struct {
+			Speed  *float64 `json:"speed_kmh"`
+			Reason string   `json:"reason"`
+		} `json:"points"`
+		Failure string `json:"failure"`
+	}
+	if err := json.Unmarshal(output.Bytes(), &got); err != nil {
+		t.Fatalf("WriteJSON() wrote invalid JSON %v: %v", output.String(), err)
+	}
+
+	// the infinite speed of two points with the same timestamp is null
+	if len(got.Points) != 2 || got.Points[1].Speed != nil || got.Points[1].Reason != RejectedOverSpeed || got.Failure != "not_enough_segments" {
+		t.Errorf("Bad output %v", output.String())
+	}
+}
+
+func TestRideTrace_WriteTable(t *testing.T) {
+	trace := TraceRide([]RidePart{
+		{1, Coord3Part1, parseDatetime("2018-12-12T04:58:00Z").UnixNano()},
+		{1, Coord1Part1, parseDatetime("2018-12-12T04:59:00Z").UnixNano()},
+		{1, Coord3Part2, parseDatetime("2018-12-12T05:01:00Z").UnixNano()},
+	}, DefaultTariff)
+
+	output := bytes.NewBufferString("")
+	if err := trace.WriteTable(output); err != nil {
+		t.Fatalf("WriteTable() returned error %v", err)
+	}
+
+	for _, want := range []string{
+		"Ride 1: 3 points, 1 valid segments\n",
+		"1  2018-12-12T04:59:00Z  51.365184  -2.388245  29225.18  false  over_100_kmh",
+		"1     0-2  1.221  0.0500  24.43  moving  night+day  1.36",
+		"Flag 1.30, minimum top-up 0.81, fare estimate 3.47\n",
+	} {
+		if !strings.Contains(output.String(), want) {
+			t.Errorf("Bad output, expected it to contain `%v`, got `%v`", want, output.String())
+		}
+	}
+}
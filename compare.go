@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"harry-pap/beat_assignment/compression"
+	"harry-pap/beat_assignment/parser"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+)
+
+// compareUsage is printed before the flags of the compare command, by -h and on invalid arguments
+const compareUsage = `Usage: fare-calculator compare [flags] [estimates_a [estimates_b]]
+
+Compares two estimate files, written by the estimate command as CSV or JSON Lines, and writes a CSV of the rides whose
+estimates differ by more than -tolerance, or which are estimated in only one of the files, sorted by ride id. The
+differences are written to the standard output, unless -output is given, and a summary to the standard error. The exit
+status is non-zero when a difference is found.
+
+Flags:
+`
+
+// comparisonHeader contains the column names of the differences written by the compare command
+var comparisonHeader = []string{"id_ride", "fare_a", "fare_b", "difference"}
+
+// runCompare runs the compare command
+func runCompare(_ context.Context, args []string) error {
+	flags := flag.NewFlagSet("compare", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), compareUsage)
+		flags.PrintDefaults()
+	}
+	outputPath := flags.String("output", compression.StdStream, "CSV to write the differences to, - for the standard output")
+	tolerance := flags.Float64("tolerance", 0.005, "amount two estimates of a ride can differ by, and still be equal")
+	var inputFormat parser.Format
+	flags.Var(&inputFormat, "input-format", "format of the estimates: csv, jsonl, or auto to detect it by the file extension")
+	if err := flags.Parse(args); err != nil {
+		return fmt.Errorf("invalid arguments: %w", err)
+	}
+
+	var pathA, pathB string
+	argsErr := resolvePositionals(flags.Args(), positional{name: "estimates_a", value: &pathA}, positional{name: "estimates_b", value: &pathB})
+	if argsErr == nil {
+		argsErr = validateArguments(flags, []numberArgument{{"tolerance", *tolerance, 0}}, []string{pathA, pathB}, *outputPath)
+	}
+	if argsErr == nil && pathA == compression.StdStream && pathB == compression.StdStream {
+		argsErr = errors.New("only one of the estimates can be read from the standard input")
+	}
+	if argsErr != nil {
+		flags.Usage()
+		return fmt.Errorf("invalid arguments: %w", argsErr)
+	}
+
+	estimatesA, err := readEstimates(pathA, inputFormat.Resolve(pathA))
+	if err != nil {
+		return err
+	}
+	estimatesB, err := readEstimates(pathB, inputFormat.Resolve(pathB))
+	if err != nil {
+		return err
+	}
+
+	outputFile, err := compression.CreateOutput(*outputPath)
+	if err != nil {
+		return err
+	}
+
+	differences, writeErr := writeDifferences(csv.NewWriter(outputFile), estimatesA, estimatesB, *tolerance)
+	writeErr = errors.Join(writeErr, outputFile.Close())
+
+	fmt.Fprintf(os.Stderr, "Compared %d and %d estimates: %d differences\n", len(estimatesA), len(estimatesB), differences)
+
+	if writeErr == nil && differences > 0 {
+		writeErr = fmt.Errorf("%d differences found", differences)
+	}
+	return writeErr
+}
+
+// readEstimates reads the estimates of the file in the given format, by ride id
+func readEstimates(path string, format parser.Format) (map[int64]float64, error) {
+	file, err := compression.OpenInput(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	estimates, err := parser.ParseEstimates(file, format)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read estimates %s: %w", path, err)
+	}
+	return estimates, nil
+}
+
+// writeDifferences writes a header, followed by the rides whose estimates differ by more than the tolerance, or which
+// are in only one of the estimates, with an empty fare and difference, sorted by ride id, and returns their number
+func writeDifferences(writer *csv.Writer, estimatesA map[int64]float64, estimatesB map[int64]float64, tolerance float64) (int, error) {
+	ids := make([]int64, 0, len(estimatesA))
+	for id := range estimatesA {
+		ids = append(ids, id)
+	}
+	for id := range estimatesB {
+		if _, found := estimatesA[id]; !found {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	writer.Write(comparisonHeader)
+
+	differences := 0
+	for _, id := range ids {
+		fareA, foundA := estimatesA[id]
+		fareB, foundB := estimatesB[id]
+		if foundA && foundB && math.Abs(fareB-fareA) <= tolerance {
+			continue
+		}
+
+		row := []string{strconv.FormatInt(id, 10), "", "", ""}
+		if foundA {
+			row[1] = fmt.Sprintf("%.2f", fareA)
+		}
+		if foundB {
+			row[2] = fmt.Sprintf("%.2f", fareB)
+		}
+		if foundA && foundB {
+			row[3] = fmt.Sprintf("%.2f", fareB-fareA)
+		}
+
+		differences++
+		writer.Write(row)
+	}
+
+	writer.Flush()
+	return differences, writer.Error()
+}
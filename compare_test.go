@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func Test_compare(t *testing.T) {
+	dir := t.TempDir()
+	estimatesA := filepath.Join(dir, "a.csv")
+	estimatesB := filepath.Join(dir, "b.jsonl")
+	output := filepath.Join(dir, "differences.csv")
+
+	os.WriteFile(estimatesA, []byte("id_ride,fare_estimate,status\n1,27.39,ok\n2,10.00,ok\n3,5.00,ok\n4,,not_enough_segments\n"), 0o644)
+	os.WriteFile(estimatesB, []byte(`{"id_ride":1,"fare_estimate":27.39}`+"\n"+`{"id_ride":2,"fare_estimate":12.50}`+"\n"+
+		`{"id_ride":5,"fare_estimate":3.47}`+"\n"), 0o644)
+
+	err := run(context.Background(), []string{"compare", "-output", output, estimatesA, estimatesB})
+	if err == nil || !strings.Contains(err.Error(), "3 differences found") {
+		t.Errorf("run() returned error %v, want 3 differences", err)
+	}
+
+	bytes, _ := os.ReadFile(output)
+	want := "id_ride,fare_a,fare_b,difference\n2,10.00,12.50,2.50\n3,5.00,,\n5,,3.47,\n"
+	if string(bytes) != want {
+		t.Errorf("Bad output, expected `%v`, got `%v`", want, string(bytes))
+	}
+}
+
+func Test_compare_equal(t *testing.T) {
+	dir := t.TempDir()
+	output := filepath.Join(dir, "differences.csv")
+	estimates := filepath.Join(dir, "estimates.csv")
+	os.WriteFile(estimates, []byte("1,27.39\n"), 0o644)
+	if err := run(context.Background(), []string{"compare", "-tolerance", "0.01", "-output", output, estimates, estimates}); err != nil {
+		t.Errorf("run() returned error %v, want no differences", err)
+	}
+
+	bytes, _ := os.ReadFile(output)
+	if string(bytes) != "id_ride,fare_a,fare_b,difference\n" {
+		t.Errorf("Bad output, expected only the header, got `%v`", string(bytes))
+	}
+}
+
+func Test_compare_arguments(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{"missing estimates", []string{"compare", "testdata/sample.csv"}, "missing estimates_b"},
+		{"negative tolerance", []string{"compare", "-tolerance", "-1", "testdata/sample.csv", "testdata/sample.csv"}, "-tolerance must be at least 0"},
+		{"both from the standard input", []string{"compare", "-", "-"}, "only one of the estimates"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := run(context.Background(), tt.args); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("run() returned error %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package compression
+
+import (
+	"context"
+	"io"
+)
+
+// contextChunkSize is the size of the chunks read ahead by the reader of NewContextReader
+const contextChunkSize = 1 << 16
+
+// contextBuffers is the number of chunk buffers of a reader of NewContextReader, so that a chunk is read ahead while
+// the previous one is consumed
+const contextBuffers = 2
+
+// contextChunk is a chunk read by the reader of NewContextReader, with the error of the read
+type contextChunk struct {
+	data []byte
+	err  error
+}
+
+// contextReader returns the chunks read by its goroutine, until the context is cancelled. The buffers of the chunks are
+// given back to the goroutine through free once they are consumed, to be read into again
+type contextReader struct {
+	ctx     context.Context
+	chunks  chan contextChunk
+	free    chan []byte
+	current []byte
+	pending []byte
+	err     error
+}
+
+// NewContextReader returns a reader of the reader, whose reads return the error of the context once it is cancelled,
+// even while the underlying read is blocked, e.g. on a stalled standard input. The reader is read a chunk ahead by a
+// goroutine, which stops once the context is cancelled, or at the first error, while a read blocked on a stream that
+// never ends is abandoned, leaving the goroutine to end with the process
+func NewContextReader(ctx context.Context, reader io.Reader) io.Reader {
+	if ctx.Done() == nil {
+		return reader
+	}
+
+	result := &contextReader{ctx: ctx, chunks: make(chan contextChunk), free: make(chan []byte, contextBuffers)}
+	for i := 0; i < contextBuffers; i++ {
+		result.free <- make([]byte, contextChunkSize)
+	}
+
+	go func() {
+		for {
+			var buffer []byte
+			select {
+			case buffer = <-result.free:
+			case <-ctx.Done():
+				return
+			}
+
+			n, err := reader.Read(buffer)
+
+			select {
+			case result.chunks <- contextChunk{data: buffer[:n], err: err}:
+			case <-ctx.Done():
+				return
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	return result
+}
+
+func (reader *contextReader) Read(p []byte) (int, error) {
+	for len(reader.pending) == 0 {
+		if reader.err != nil {
+			return 0, reader.err
+		}
+
+		if reader.current != nil {
+			reader.free <- reader.current[:cap(reader.current)]
+			reader.current = nil
+		}
+
+		select {
+		case chunk := <-reader.chunks:
+			reader.current, reader.pending, reader.err = chunk.data, chunk.data, chunk.err
+		case <-reader.ctx.Done():
+			reader.err = reader.ctx.Err()
+		}
+	}
+
+	n := copy(p, reader.pending)
+	reader.pending = reader.pending[n:]
+	return n, nil
+}
@@ -0,0 +1,53 @@
+package compression
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewContextReader(t *testing.T) {
+	content := strings.Repeat("1,49.143352,3.519707,1544590680\n", 5000)
+
+	got, err := io.ReadAll(NewContextReader(context.Background(), strings.NewReader(content)))
+	if err != nil || string(got) != content {
+		t.Errorf("Read %d bytes, %v, want %d bytes", len(got), err, len(content))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	got, err = io.ReadAll(NewContextReader(ctx, strings.NewReader(content)))
+	if err != nil || string(got) != content {
+		t.Errorf("Read %d bytes, %v, want %d bytes", len(got), err, len(content))
+	}
+}
+
+func TestNewContextReader_cancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	// the pipe is never written to, so the underlying read blocks
+	pipeReader, pipeWriter := io.Pipe()
+	defer pipeWriter.Close()
+	reader := NewContextReader(ctx, pipeReader)
+
+	result := make(chan error)
+	go func() {
+		_, err := reader.Read(make([]byte, 10))
+		result <- err
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+
+	select {
+	case err := <-result:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Read() returned error %v, want %v", err, context.Canceled)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Read() is still blocked after the cancellation")
+	}
+}
@@ -0,0 +1,126 @@
+package compression
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// StdStream is the path standing for the standard input, or the standard output
+const StdStream = "-"
+
+var gzipMagic = []byte{0x1f, 0x8b}
+var zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+
+// OpenInput opens the file at the given path for reading, or the standard input when the path is StdStream.
+// Gzip and zstd compressed input is detected by its magic number, and decompressed transparently
+func OpenInput(path string) (io.ReadCloser, error) {
+	return OpenInputContext(context.Background(), path)
+}
+
+// IsCompressed returns whether the file starts with the magic number of gzip or zstd, for the inputs which cannot be
+// decompressed transparently, because they are read at random
+func IsCompressed(file io.ReaderAt) bool {
+	magic := make([]byte, len(zstdMagic))
+	n, _ := file.ReadAt(magic, 0)
+
+	return bytes.HasPrefix(magic[:n], gzipMagic) || bytes.HasPrefix(magic[:n], zstdMagic)
+}
+
+// OpenInputContext is OpenInput, whose reads return the error of the context once it is cancelled, even while blocked
+// on the standard input or a pipe, which are read through NewContextReader before being decompressed. Regular files
+// are read directly, as their reads do not block
+func OpenInputContext(ctx context.Context, path string) (io.ReadCloser, error) {
+	file, closeFile := os.Stdin, func() error { return nil }
+
+	if path != StdStream {
+		opened, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		file, closeFile = opened, opened.Close
+	}
+
+	var input io.Reader = file
+	if info, err := file.Stat(); err != nil || !info.Mode().IsRegular() {
+		input = NewContextReader(ctx, file)
+	}
+
+	reader := bufio.NewReaderSize(input, 1<<16)
+	magic, _ := reader.Peek(len(zstdMagic))
+
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		decompressor, err := gzip.NewReader(reader)
+		if err != nil {
+			closeFile()
+			return nil, err
+		}
+		return &streamCloser{Reader: decompressor, closers: []func() error{decompressor.Close, closeFile}}, nil
+	case bytes.HasPrefix(magic, zstdMagic):
+		decompressor, err := zstd.NewReader(reader)
+		if err != nil {
+			closeFile()
+			return nil, err
+		}
+		closeDecompressor := func() error {
+			decompressor.Close()
+			return nil
+		}
+		return &streamCloser{Reader: decompressor, closers: []func() error{closeDecompressor, closeFile}}, nil
+	default:
+		return &streamCloser{Reader: reader, closers: []func() error{closeFile}}, nil
+	}
+}
+
+// CreateOutput creates the file at the given path for writing, or writes to the standard output when the path is
+// StdStream. Output to a path ending in .gz or .zst, in any case like parser.Format.Resolve, is compressed with gzip or
+// zstd, and is complete once closed
+func CreateOutput(path string) (io.WriteCloser, error) {
+	if path == StdStream {
+		return &streamCloser{Writer: os.Stdout}, nil
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case strings.HasSuffix(strings.ToLower(path), ".gz"):
+		compressor := gzip.NewWriter(file)
+		return &streamCloser{Writer: compressor, closers: []func() error{compressor.Close, file.Close}}, nil
+	case strings.HasSuffix(strings.ToLower(path), ".zst"):
+		compressor, err := zstd.NewWriter(file)
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+		return &streamCloser{Writer: compressor, closers: []func() error{compressor.Close, file.Close}}, nil
+	default:
+		return &streamCloser{Writer: file, closers: []func() error{file.Close}}, nil
+	}
+}
+
+// streamCloser closes the layers of a stream in order, from the (de)compressor to the file, returning the first error
+type streamCloser struct {
+	io.Reader
+	io.Writer
+	closers []func() error
+}
+
+func (stream *streamCloser) Close() error {
+	var result error
+	for _, close := range stream.closers {
+		if err := close(); err != nil && result == nil {
+			result = err
+		}
+	}
+	return result
+}
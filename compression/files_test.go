@@ -0,0 +1,139 @@
+package compression
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCreateOutput_OpenInput(t *testing.T) {
+	const content = "1,49.143352,3.519707,1544590680\n1,49.150684,3.532212,1544590860\n"
+
+	tests := []struct {
+		name       string
+		fileName   string
+		compressed bool
+	}{
+		{"plain", "rides.csv", false},
+		{"gzip", "rides.csv.gz", true},
+		{"zstd", "rides.csv.zst", true},
+		{"upper case gzip extension", "RIDES.JSONL.GZ", true},
+		{"upper case zstd extension", "RIDES.CSV.ZST", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tt.fileName)
+
+			output, err := CreateOutput(path)
+			if err != nil {
+				t.Fatalf("CreateOutput() returned error %v", err)
+			}
+			if _, err := io.WriteString(output, content); err != nil {
+				t.Fatalf("Write() returned error %v", err)
+			}
+			if err := output.Close(); err != nil {
+				t.Fatalf("Close() returned error %v", err)
+			}
+
+			raw, _ := os.ReadFile(path)
+			if (string(raw) != content) != tt.compressed {
+				t.Errorf("CreateOutput() wrote %q, want compressed: %v", raw, tt.compressed)
+			}
+
+			input, err := OpenInput(path)
+			if err != nil {
+				t.Fatalf("OpenInput() returned error %v", err)
+			}
+			defer input.Close()
+
+			if got, err := io.ReadAll(input); err != nil || string(got) != content {
+				t.Errorf("OpenInput() read %q, %v, want %q", got, err, content)
+			}
+		})
+	}
+}
+
+func TestOpenInput_compressedWithoutExtension(t *testing.T) {
+	const content = "1,2,3,4\n"
+	dir := t.TempDir()
+
+	for _, extension := range []string{".gz", ".zst"} {
+		output, _ := CreateOutput(filepath.Join(dir, "rides"+extension))
+		io.WriteString(output, content)
+		output.Close()
+
+		renamed := filepath.Join(dir, "rides"+extension+".dump")
+		if err := os.Rename(filepath.Join(dir, "rides"+extension), renamed); err != nil {
+			t.Fatalf("Rename() returned error %v", err)
+		}
+
+		input, err := OpenInput(renamed)
+		if err != nil {
+			t.Fatalf("OpenInput() returned error %v", err)
+		}
+		if got, err := io.ReadAll(input); err != nil || string(got) != content {
+			t.Errorf("OpenInput() of %s read %q, %v, want %q", extension, got, err, content)
+		}
+		input.Close()
+	}
+}
+
+func TestOpenInputContext_regularFile(t *testing.T) {
+	const content = "1,2,3,4\n"
+	path := filepath.Join(t.TempDir(), "rides.csv")
+	os.WriteFile(path, []byte(content), 0o644)
+
+	// the reads of a regular file never block, so they are not interrupted by the context
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	input, err := OpenInputContext(ctx, path)
+	if err != nil {
+		t.Fatalf("OpenInputContext() returned error %v", err)
+	}
+	defer input.Close()
+
+	if got, err := io.ReadAll(input); err != nil || string(got) != content {
+		t.Errorf("OpenInputContext() read %q, %v, want %q", got, err, content)
+	}
+}
+
+func TestIsCompressed(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := []struct {
+		name     string
+		fileName string
+		want     bool
+	}{
+		{"plain", "rides.parquet", false},
+		{"gzip", "rides.parquet.gz", true},
+		{"zstd", "rides.parquet.zst", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, tt.fileName)
+			output, _ := CreateOutput(path)
+			io.WriteString(output, "PAR1")
+			output.Close()
+
+			file, err := os.Open(path)
+			if err != nil {
+				t.Fatalf("Open() returned error %v", err)
+			}
+			defer file.Close()
+
+			if got := IsCompressed(file); got != tt.want {
+				t.Errorf("IsCompressed() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOpenInput_missingFile(t *testing.T) {
+	if _, err := OpenInput(filepath.Join(t.TempDir(), "missing.csv")); err == nil {
+		t.Errorf("OpenInput() of a missing file returned no error")
+	}
+}
@@ -0,0 +1,126 @@
+package concurrency
+
+import (
+	"context"
+	"harry-pap/beat_assignment/calculator"
+	"harry-pap/beat_assignment/model"
+	"sort"
+	"sync"
+)
+
+// Job contains the parts of a ride, with the position of the ride in the input
+type Job struct {
+	Sequence int
+	Parts    []calculator.RidePart
+}
+
+// Result contains the estimate of a Job, or the error of its calculation, with the sequence number of the Job
+type Result struct {
+	Sequence   int
+	Estimation model.RideFareEstimation
+	Err        error
+}
+
+// SequencerInput contains the input of SequenceJobs
+type SequencerInput struct {
+	Rides chan []calculator.RidePart
+	Jobs  chan Job
+	// Slots bounds the number of jobs between SequenceJobs and CollectResults, nil for no bound
+	Slots chan struct{}
+	Wg    *sync.WaitGroup
+}
+
+// CollectorInput contains the input of CollectResults
+type CollectorInput struct {
+	Results   chan Result
+	Estimates chan model.RideFareEstimation
+	// Ordered pushes the estimates in the order of their sequence numbers, instead of the order they are calculated in
+	Ordered bool
+	// Slots is the channel of SequencerInput.Slots, a slot of which is released for every collected result
+	Slots chan struct{}
+	Wg    *sync.WaitGroup
+}
+
+// NewSlots returns the channel bounding the jobs between SequenceJobs and CollectResults to count, or nil when count
+// is 0 or less
+func NewSlots(count int) chan struct{} {
+	if count <= 0 {
+		return nil
+	}
+	return make(chan struct{}, count)
+}
+
+// SequenceJobs reads the SequencerInput.Rides channel, and pushes each ride into the SequencerInput.Jobs channel as a
+// Job, numbered in the order the rides are read. When SequencerInput.Slots is set, a slot is taken for every job, so
+// that it blocks while all the slots are taken
+// When the context is cancelled, it stops pushing jobs
+// Upon completion the SequencerInput.Jobs channel is closed, and sync.WaitGroup.Done() is invoked
+func SequenceJobs(ctx context.Context, input SequencerInput) {
+	defer input.Wg.Done()
+	defer close(input.Jobs)
+
+	sequence := 0
+	for ride := range input.Rides {
+		if input.Slots != nil {
+			select {
+			case input.Slots <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		select {
+		case input.Jobs <- Job{Sequence: sequence, Parts: ride}:
+		case <-ctx.Done():
+			return
+		}
+		sequence++
+	}
+}
+
+// CollectResults reads the CollectorInput.Results channel, and pushes the estimate of each result into the
+// CollectorInput.Estimates channel, including the ones of the failed results, which carry their model.RideFailure.
+// With CollectorInput.Ordered the results are buffered until the ones preceding them are collected, so the buffer
+// holds at most as many results as the slots of SequenceJobs. When the pipeline is cancelled, some of the jobs are never
+// calculated, so the results still buffered once the channel is closed are pushed in order, skipping the missing ones
+// Upon completion the CollectorInput.Estimates channel is closed, and sync.WaitGroup.Done() is invoked
+func CollectResults(input CollectorInput) {
+	defer input.Wg.Done()
+
+	emit := func(result Result) {
+		input.Estimates <- result.Estimation
+
+		if input.Slots != nil {
+			<-input.Slots
+		}
+	}
+
+	pending := make(map[int]Result)
+	next := 0
+
+	for result := range input.Results {
+		if !input.Ordered {
+			emit(result)
+			continue
+		}
+
+		pending[result.Sequence] = result
+		for buffered, found := pending[next]; found; buffered, found = pending[next] {
+			delete(pending, next)
+			emit(buffered)
+			next++
+		}
+	}
+
+	remaining := make([]int, 0, len(pending))
+	for sequence := range pending {
+		remaining = append(remaining, sequence)
+	}
+	sort.Ints(remaining)
+
+	for _, sequence := range remaining {
+		emit(pending[sequence])
+	}
+
+	close(input.Estimates)
+}
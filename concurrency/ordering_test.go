@@ -0,0 +1,181 @@
+package concurrency
+
+import (
+	"context"
+	"harry-pap/beat_assignment/calculator"
+	"harry-pap/beat_assignment/model"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSequenceJobs(t *testing.T) {
+	rides := make(chan []calculator.RidePart, 3)
+	rides <- []calculator.RidePart{{RideID: 7}}
+	rides <- []calculator.RidePart{{RideID: 3}}
+	rides <- []calculator.RidePart{{RideID: 5}}
+	close(rides)
+
+	jobs := make(chan Job, 3)
+	slots := NewSlots(3)
+
+	SequenceJobs(context.Background(), SequencerInput{Rides: rides, Jobs: jobs, Slots: slots, Wg: newGroup()})
+
+	got := make([]Job, 0, 3)
+	for job := range jobs {
+		got = append(got, job)
+	}
+
+	want := []Job{
+		{Sequence: 0, Parts: []calculator.RidePart{{RideID: 7}}},
+		{Sequence: 1, Parts: []calculator.RidePart{{RideID: 3}}},
+		{Sequence: 2, Parts: []calculator.RidePart{{RideID: 5}}},
+	}
+	if !reflect.DeepEqual(got, want) || len(slots) != 3 {
+		t.Errorf("SequenceJobs pushed %v, taking %d slots, want %v, taking 3 slots", got, len(slots), want)
+	}
+}
+
+func TestSequenceJobs_cancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	rides := make(chan []calculator.RidePart, 3)
+	rides <- []calculator.RidePart{{RideID: 7}}
+	rides <- []calculator.RidePart{{RideID: 3}}
+	rides <- []calculator.RidePart{{RideID: 5}}
+	close(rides)
+
+	jobs := make(chan Job, 3)
+	// the second job waits for a slot, until the context is cancelled
+	slots := NewSlots(1)
+
+	time.AfterFunc(20*time.Millisecond, cancel)
+	SequenceJobs(ctx, SequencerInput{Rides: rides, Jobs: jobs, Slots: slots, Wg: newGroup()})
+
+	got := make([]Job, 0, 3)
+	for job := range jobs {
+		got = append(got, job)
+	}
+
+	want := []Job{{Sequence: 0, Parts: []calculator.RidePart{{RideID: 7}}}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("SequenceJobs pushed %v after cancellation, want %v", got, want)
+	}
+}
+
+func TestCollectResults(t *testing.T) {
+	type args struct {
+		ordered bool
+		results []Result
+	}
+	tests := []struct {
+		name string
+		args args
+		want []model.RideFareEstimation
+	}{
+		{
+			"unordered results are pushed as collected",
+			args{false, []Result{{2, sampleFareEstimation3, nil}, {0, sampleFareEstimation1, nil}, {1, sampleFareEstimation2, nil}}},
+			[]model.RideFareEstimation{sampleFareEstimation3, sampleFareEstimation1, sampleFareEstimation2},
+		},
+		{
+			"ordered results are pushed by sequence number",
+			args{true, []Result{{2, sampleFareEstimation3, nil}, {0, sampleFareEstimation1, nil}, {1, sampleFareEstimation2, nil}}},
+			[]model.RideFareEstimation{sampleFareEstimation1, sampleFareEstimation2, sampleFareEstimation3},
+		},
+		{
+			"failed results are pushed in order too",
+			args{true, []Result{{2, sampleFareEstimation3, nil}, {0, sampleFareEstimation1, nil}, {1, failedFareEstimation, errCalculation}}},
+			[]model.RideFareEstimation{sampleFareEstimation1, failedFareEstimation, sampleFareEstimation3},
+		},
+		{
+			"results after a missing one, of a cancelled pipeline, are pushed in order once the channel is closed",
+			args{true, []Result{{3, sampleFareEstimation3, nil}, {0, sampleFareEstimation1, nil}, {2, sampleFareEstimation2, nil}}},
+			[]model.RideFareEstimation{sampleFareEstimation1, sampleFareEstimation2, sampleFareEstimation3},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results := make(chan Result, len(tt.args.results))
+			for _, result := range tt.args.results {
+				results <- result
+			}
+			close(results)
+
+			slots := NewSlots(len(tt.args.results))
+			for range tt.args.results {
+				slots <- struct{}{}
+			}
+
+			estimates := make(chan model.RideFareEstimation, len(tt.args.results))
+
+			CollectResults(CollectorInput{Results: results, Estimates: estimates, Ordered: tt.args.ordered, Slots: slots, Wg: newGroup()})
+
+			got := make([]model.RideFareEstimation, 0, len(tt.args.results))
+			for estimation := range estimates {
+				got = append(got, estimation)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) || len(slots) != 0 {
+				t.Errorf("CollectResults pushed %v, leaving %d slots taken, want %v, leaving none", got, len(slots), tt.want)
+			}
+		})
+	}
+}
+
+// The whole pipeline, with workers finishing in reverse order, pushes the estimates in input order, through a buffer
+// bounded by the slots
+func TestCollectResults_boundedPipeline(t *testing.T) {
+	const rides, window = 50, 4
+
+	var wg sync.WaitGroup
+	wg.Add(3)
+
+	input := make(chan []calculator.RidePart)
+	jobs := make(chan Job)
+	results := make(chan Result)
+	estimates := make(chan model.RideFareEstimation)
+	slots := NewSlots(window)
+
+	go SequenceJobs(context.Background(), SequencerInput{Rides: input, Jobs: jobs, Slots: slots, Wg: &wg})
+	// a single worker, which calculates the jobs of each batch of window jobs in reverse order
+	go func() {
+		defer wg.Done()
+		batch := make([]Job, 0, window)
+		calculate := func() {
+			for i := len(batch) - 1; i >= 0; i-- {
+				results <- Result{Sequence: batch[i].Sequence, Estimation: model.RideFareEstimation{RideID: batch[i].Parts[0].RideID}}
+			}
+			batch = batch[:0]
+		}
+		for job := range jobs {
+			if batch = append(batch, job); len(batch) == window {
+				calculate()
+			}
+		}
+		calculate()
+		close(results)
+	}()
+	go CollectResults(CollectorInput{Results: results, Estimates: estimates, Ordered: true, Slots: slots, Wg: &wg})
+
+	go func() {
+		for i := int64(0); i < rides; i++ {
+			input <- []calculator.RidePart{{RideID: i}}
+		}
+		close(input)
+	}()
+
+	next := int64(0)
+	for estimation := range estimates {
+		if estimation.RideID != next {
+			t.Errorf("CollectResults pushed ride %d, want %d", estimation.RideID, next)
+		}
+		next++
+	}
+	wg.Wait()
+
+	if next != rides {
+		t.Errorf("CollectResults pushed %d rides, want %d", next, rides)
+	}
+}
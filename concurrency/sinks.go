@@ -0,0 +1,402 @@
+package concurrency
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"harry-pap/beat_assignment/audit"
+	"harry-pap/beat_assignment/model"
+	"io"
+	"strconv"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// ResultSink receives the estimates of the rides, one at a time, and writes them somewhere.
+// Close must be called once after the last estimate, to write anything that is buffered, and does not close the
+// underlying writer
+type ResultSink interface {
+	Write(estimation model.RideFareEstimation) error
+	Close() error
+}
+
+// SinkWriter reads the RideFareEstimation channel, and writes each estimate into the sink, closing it once the channel
+// is closed, and returns the number of estimates written. After an error the channel is still read to its end, so that
+// the workers are not blocked, and the first error is returned
+// Unlike the other stages, it does not take a sync.WaitGroup, so that the caller marks it done once the results are
+// assigned, and reads them only after waiting
+func SinkWriter(sink ResultSink, inputs chan model.RideFareEstimation) (int, error) {
+	written := 0
+	var err error
+	for input := range inputs {
+		if err == nil {
+			if err = sink.Write(input); err == nil {
+				written++
+			}
+		}
+	}
+
+	if closeErr := sink.Close(); err == nil {
+		err = closeErr
+	}
+	return written, err
+}
+
+// multiSink writes each estimate into every one of its sinks
+type multiSink struct {
+	sinks []ResultSink
+}
+
+// NewMultiSink returns a ResultSink writing each estimate into every one of the sinks, in order.
+// An estimate is written into all the sinks even if one of them fails, and the errors are joined
+func NewMultiSink(sinks ...ResultSink) ResultSink {
+	return &multiSink{sinks: sinks}
+}
+
+func (sink *multiSink) Write(estimation model.RideFareEstimation) error {
+	errs := make([]error, 0, len(sink.sinks))
+	for _, s := range sink.sinks {
+		errs = append(errs, s.Write(estimation))
+	}
+	return errors.Join(errs...)
+}
+
+func (sink *multiSink) Close() error {
+	errs := make([]error, 0, len(sink.sinks))
+	for _, s := range sink.sinks {
+		errs = append(errs, s.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// SinkOptions contains the columns written by the estimate sinks
+type SinkOptions struct {
+	// BreakdownHeader, when set, writes each estimate followed by its breakdown, with these columns
+	BreakdownHeader []string
+	// Status writes the failed rides too, with a status column, which is model.StatusOK for the estimated rides, and
+	// the kind of error for the failed ones, whose other columns are empty. Otherwise, the failed rides are skipped
+	Status bool
+}
+
+// header returns the columns written with the options, before the status one
+func (options SinkOptions) header() []string {
+	if options.BreakdownHeader != nil {
+		return options.BreakdownHeader
+	}
+	return model.EstimationHeader
+}
+
+// row returns the fields of the estimate for the columns of header, before the status one.
+// The fields of a failed ride are empty, but for its id
+func (options SinkOptions) row(estimation model.RideFareEstimation) []string {
+	if estimation.Failure != nil {
+		result := make([]string, len(options.header()))
+		result[0] = strconv.FormatInt(estimation.RideID, 10)
+		return result
+	}
+
+	if options.BreakdownHeader != nil {
+		return estimation.ToBreakdownStringSlice()
+	}
+	return estimation.ToStringSlice()
+}
+
+// skip returns whether the estimate is not written, as it belongs to a failed ride, and there is no status column
+func (options SinkOptions) skip(estimation model.RideFareEstimation) bool {
+	return estimation.Failure != nil && !options.Status
+}
+
+// csvSink writes each estimate as a CSV line, after the header if there is one
+type csvSink struct {
+	writer        *csv.Writer
+	header        []string
+	headerWritten bool
+	options       SinkOptions
+}
+
+// NewCSVSink returns a ResultSink writing each estimate as a line of CSV into the writer. A header is written when
+// there is a breakdown, along with the status column if there is one
+func NewCSVSink(writer io.Writer, options SinkOptions) ResultSink {
+	header := options.BreakdownHeader
+	if header != nil && options.Status {
+		header = append(append([]string(nil), header...), "status")
+	}
+
+	return &csvSink{writer: csv.NewWriter(writer), header: header, options: options}
+}
+
+func (sink *csvSink) writeHeader() error {
+	if sink.headerWritten || sink.header == nil {
+		return nil
+	}
+	sink.headerWritten = true
+
+	return sink.writer.Write(sink.header)
+}
+
+func (sink *csvSink) Write(estimation model.RideFareEstimation) error {
+	if err := sink.writeHeader(); err != nil {
+		return err
+	}
+	if sink.options.skip(estimation) {
+		return nil
+	}
+
+	row := sink.options.row(estimation)
+	if sink.options.Status {
+		row = append(row, estimation.Status())
+	}
+	return sink.writer.Write(row)
+}
+
+func (sink *csvSink) Close() error {
+	if err := sink.writeHeader(); err != nil {
+		return err
+	}
+	sink.writer.Flush()
+	return sink.writer.Error()
+}
+
+// jsonSink writes each estimate as a line of JSON
+type jsonSink struct {
+	writer  *bufio.Writer
+	options SinkOptions
+}
+
+// NewJSONSink returns a ResultSink writing each estimate as a line of JSON into the writer, with a breakdown object
+// when there is a breakdown, and a status key when there is a status column
+func NewJSONSink(writer io.Writer, options SinkOptions) ResultSink {
+	return &jsonSink{writer: bufio.NewWriter(writer), options: options}
+}
+
+func (sink *jsonSink) Write(estimation model.RideFareEstimation) error {
+	if sink.options.skip(estimation) {
+		return nil
+	}
+
+	var line []byte
+	var err error
+	switch {
+	case sink.options.Status:
+		line, err = estimation.ToStatusJSON(sink.options.BreakdownHeader != nil)
+	case sink.options.BreakdownHeader != nil:
+		line, err = estimation.ToBreakdownJSON()
+	default:
+		line, err = estimation.ToJSON()
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = sink.writer.Write(append(line, '\n'))
+	return err
+}
+
+func (sink *jsonSink) Close() error {
+	return sink.writer.Flush()
+}
+
+// parquetIntegerColumns are the columns written as INT64, while the others are written as DOUBLE
+var parquetIntegerColumns = map[string]bool{"id_ride": true, "discarded_points": true, "reordered_points": true}
+
+// parquetBatchSize is the number of rows written to the Parquet writer at once
+const parquetBatchSize = 1024
+
+// parquetSink writes the estimates as the rows of a Parquet file
+type parquetSink struct {
+	writer  *parquet.Writer
+	header  []string
+	options SinkOptions
+	// columnIndexes contains the index of the column of each field of a row, followed by the one of the status
+	columnIndexes []int
+	rows          []parquet.Row
+}
+
+// NewParquetSink returns a ResultSink writing the estimates as the rows of a Parquet file into the writer, with the
+// columns of model.EstimationHeader, or of the breakdown. With a status column, which is a string, the columns other
+// than id_ride are optional, and null for the failed rides
+func NewParquetSink(writer io.Writer, options SinkOptions) ResultSink {
+	header := options.header()
+
+	group := parquet.Group{}
+	for _, name := range header {
+		var node parquet.Node
+		if parquetIntegerColumns[name] {
+			node = parquet.Leaf(parquet.Int64Type)
+		} else {
+			node = parquet.Leaf(parquet.DoubleType)
+		}
+
+		if options.Status && name != "id_ride" {
+			node = parquet.Optional(node)
+		}
+		group[name] = node
+	}
+	if options.Status {
+		group["status"] = parquet.String()
+	}
+	schema := parquet.NewSchema("fare_estimates", group)
+
+	// the columns of the schema are sorted by name, so each field is written at the index of its column
+	columnIndexes := make([]int, 0, len(header)+1)
+	for _, name := range header {
+		column, _ := schema.Lookup(name)
+		columnIndexes = append(columnIndexes, column.ColumnIndex)
+	}
+	if options.Status {
+		column, _ := schema.Lookup("status")
+		columnIndexes = append(columnIndexes, column.ColumnIndex)
+	}
+
+	return &parquetSink{
+		writer:        parquet.NewWriter(writer, schema, parquet.Compression(&parquet.Snappy)),
+		header:        header,
+		options:       options,
+		columnIndexes: columnIndexes,
+		rows:          make([]parquet.Row, 0, parquetBatchSize),
+	}
+}
+
+func (sink *parquetSink) Write(estimation model.RideFareEstimation) error {
+	if sink.options.skip(estimation) {
+		return nil
+	}
+
+	fields := sink.options.row(estimation)
+	if len(fields) != len(sink.header) {
+		return fmt.Errorf("cannot write ride %d: %d fields, for %d columns", estimation.RideID, len(fields), len(sink.header))
+	}
+	row := make(parquet.Row, len(sink.columnIndexes))
+
+	for i, field := range fields {
+		column := sink.columnIndexes[i]
+
+		var value parquet.Value
+		if parquetIntegerColumns[sink.header[i]] {
+			number, _ := strconv.ParseInt(field, 10, 64)
+			value = parquet.Int64Value(number)
+		} else {
+			number, _ := strconv.ParseFloat(field, 64)
+			value = parquet.DoubleValue(number)
+		}
+
+		switch {
+		case !sink.options.Status || sink.header[i] == "id_ride":
+			row[column] = value.Level(0, 0, column)
+		case estimation.Failure != nil:
+			row[column] = parquet.NullValue().Level(0, 0, column)
+		default:
+			row[column] = value.Level(0, 1, column)
+		}
+	}
+	if sink.options.Status {
+		column := sink.columnIndexes[len(fields)]
+		row[column] = parquet.ByteArrayValue([]byte(estimation.Status())).Level(0, 0, column)
+	}
+
+	if sink.rows = append(sink.rows, row); len(sink.rows) == parquetBatchSize {
+		return sink.flush()
+	}
+	return nil
+}
+
+func (sink *parquetSink) flush() error {
+	_, err := sink.writer.WriteRows(sink.rows)
+	sink.rows = sink.rows[:0]
+	return err
+}
+
+func (sink *parquetSink) Close() error {
+	if err := sink.flush(); err != nil {
+		return err
+	}
+	return sink.writer.Close()
+}
+
+// flaggedRideSink writes the flagged rides as CSV lines, after a header
+type flaggedRideSink struct {
+	csvSink
+	auditor audit.Auditor
+}
+
+// NewFlaggedRideSink returns a ResultSink checking each estimate with the audit.Auditor, and writing a header followed
+// by the flagged rides into the writer, while the failed rides are skipped
+func NewFlaggedRideSink(writer io.Writer, auditor audit.Auditor) ResultSink {
+	return &flaggedRideSink{csvSink: csvSink{writer: csv.NewWriter(writer), header: model.FlaggedRideHeader}, auditor: auditor}
+}
+
+func (sink *flaggedRideSink) Write(estimation model.RideFareEstimation) error {
+	if err := sink.writeHeader(); err != nil {
+		return err
+	}
+
+	if estimation.Failure != nil {
+		return nil
+	}
+
+	flaggedRide, flagged := sink.auditor.Check(estimation)
+	if !flagged {
+		return nil
+	}
+
+	return sink.writer.Write(flaggedRide.ToStringSlice())
+}
+
+// failureSink writes the failure records of the failed rides as CSV lines, after a header
+type failureSink struct {
+	csvSink
+}
+
+// NewFailureSink returns a ResultSink writing a header followed by the model.RideFailure of each failed ride into the
+// writer, while the estimated rides are skipped
+func NewFailureSink(writer io.Writer) ResultSink {
+	return &failureSink{csvSink: csvSink{writer: csv.NewWriter(writer), header: model.RideFailureHeader}}
+}
+
+func (sink *failureSink) Write(estimation model.RideFareEstimation) error {
+	if err := sink.writeHeader(); err != nil {
+		return err
+	}
+
+	if estimation.Failure == nil {
+		return nil
+	}
+
+	return sink.writer.Write(estimation.Failure.ToStringSlice())
+}
+
+// driverReportSink adds the estimates to a report, which is written on Close
+type driverReportSink struct {
+	writer io.Writer
+	report *audit.DriverReport
+}
+
+// NewDriverReportSink returns a ResultSink adding each estimate to the audit.DriverReport, and writing a header
+// followed by the ranked driver summaries into the writer, once it is closed. The failed rides are skipped
+func NewDriverReportSink(writer io.Writer, report *audit.DriverReport) ResultSink {
+	return &driverReportSink{writer: writer, report: report}
+}
+
+func (sink *driverReportSink) Write(estimation model.RideFareEstimation) error {
+	if estimation.Failure == nil {
+		sink.report.Add(estimation)
+	}
+	return nil
+}
+
+func (sink *driverReportSink) Close() error {
+	csvWriter := csv.NewWriter(sink.writer)
+
+	if err := csvWriter.Write(model.DriverSummaryHeader); err != nil {
+		return err
+	}
+
+	for i, summary := range sink.report.Summaries() {
+		if err := csvWriter.Write(summary.ToStringSlice(i + 1)); err != nil {
+			return err
+		}
+	}
+	csvWriter.Flush()
+	return csvWriter.Error()
+}
@@ -0,0 +1,295 @@
+package concurrency
+
+import (
+	"bytes"
+	"errors"
+	"harry-pap/beat_assignment/audit"
+	"harry-pap/beat_assignment/model"
+	"reflect"
+	"testing"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// writeToSink writes the estimates into the sink through SinkWriter
+func writeToSink(sink ResultSink, estimations ...model.RideFareEstimation) error {
+	inputs := make(chan model.RideFareEstimation, len(estimations))
+	for _, estimation := range estimations {
+		inputs <- estimation
+	}
+	close(inputs)
+
+	_, err := SinkWriter(sink, inputs)
+	return err
+}
+
+func TestCSVSink(t *testing.T) {
+	type args struct {
+		options     SinkOptions
+		estimations []model.RideFareEstimation
+	}
+	tests := []struct {
+		name string
+		args args
+		want string
+	}{
+		{"with 2 rides",
+			args{SinkOptions{}, []model.RideFareEstimation{sampleFareEstimation1, sampleFareEstimation2}},
+			"1,14.51\n2,45.12\n",
+		},
+		{"without rides",
+			args{SinkOptions{}, nil},
+			"",
+		},
+		{"failed rides are skipped",
+			args{SinkOptions{}, []model.RideFareEstimation{sampleFareEstimation1, failedFareEstimation}},
+			"1,14.51\n",
+		},
+		{"failed rides are written with a status column",
+			args{SinkOptions{Status: true}, []model.RideFareEstimation{sampleFareEstimation1, failedFareEstimation}},
+			"1,14.51,ok\n4,,not_enough_segments\n",
+		},
+		{"failed rides are written with a status column and a breakdown",
+			args{SinkOptions{BreakdownHeader: model.BreakdownHeader(nil), Status: true}, []model.RideFareEstimation{failedFareEstimation}},
+			"id_ride,fare_estimate,flag,idle_hours,idle_cost,minimum_top_up,discarded_points,reordered_points,status\n" +
+				"4,,,,,,,,not_enough_segments\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output := bytes.NewBufferString("")
+
+			if err := writeToSink(NewCSVSink(output, tt.args.options), tt.args.estimations...); err != nil {
+				t.Fatalf("SinkWriter() returned error %v", err)
+			}
+
+			if output.String() != tt.want {
+				t.Errorf("Bad output, expected `%v`, got `%v`", tt.want, output.String())
+			}
+		})
+	}
+}
+
+func TestBreakdownCSVSink(t *testing.T) {
+	output := bytes.NewBufferString("")
+
+	err := writeToSink(NewCSVSink(output, SinkOptions{BreakdownHeader: model.BreakdownHeader([]string{"day"})}),
+		model.RideFareEstimation{RideID: 1, CostEstimation: 14.51, Breakdown: model.FareBreakdown{
+			Flag:      1.3,
+			Bands:     []model.BandCharge{{Name: "day", MovingKm: 2, MovingCost: 1.48}},
+			IdleHours: 1, IdleCost: 11.73,
+		}})
+
+	want := "id_ride,fare_estimate,flag,moving_day_km,moving_day_cost,idle_hours,idle_cost,minimum_top_up,discarded_points,reordered_points\n" +
+		"1,14.51,1.30,2.000,1.48,1.0000,11.73,0.00,0,0\n"
+	if err != nil || output.String() != want {
+		t.Errorf("Bad output, expected `%v`, got `%v`, %v", want, output.String(), err)
+	}
+}
+
+func TestJSONSink(t *testing.T) {
+	output := bytes.NewBufferString("")
+
+	err := writeToSink(NewJSONSink(output, SinkOptions{}), sampleFareEstimation1, failedFareEstimation, sampleFareEstimation2)
+
+	want := `{"id_ride":1,"fare_estimate":14.51}` + "\n" + `{"id_ride":2,"fare_estimate":45.12}` + "\n"
+	if err != nil || output.String() != want {
+		t.Errorf("Bad output, expected `%v`, got `%v`, %v", want, output.String(), err)
+	}
+}
+
+func TestJSONSink_status(t *testing.T) {
+	output := bytes.NewBufferString("")
+
+	err := writeToSink(NewJSONSink(output, SinkOptions{Status: true}), sampleFareEstimation1, failedFareEstimation)
+
+	want := `{"id_ride":1,"fare_estimate":14.51,"status":"ok"}` + "\n" + `{"id_ride":4,"status":"not_enough_segments"}` + "\n"
+	if err != nil || output.String() != want {
+		t.Errorf("Bad output, expected `%v`, got `%v`, %v", want, output.String(), err)
+	}
+}
+
+func TestBreakdownParquetSink(t *testing.T) {
+	type parquetEstimation struct {
+		RideID          int64   `parquet:"id_ride"`
+		CostEstimation  float64 `parquet:"fare_estimate"`
+		Flag            float64 `parquet:"flag"`
+		MovingDayKm     float64 `parquet:"moving_day_km"`
+		MovingDayCost   float64 `parquet:"moving_day_cost"`
+		IdleHours       float64 `parquet:"idle_hours"`
+		IdleCost        float64 `parquet:"idle_cost"`
+		MinimumTopUp    float64 `parquet:"minimum_top_up"`
+		DiscardedPoints int64   `parquet:"discarded_points"`
+		ReorderedPoints int64   `parquet:"reordered_points"`
+	}
+
+	output := bytes.NewBuffer(nil)
+
+	err := writeToSink(NewParquetSink(output, SinkOptions{BreakdownHeader: model.BreakdownHeader([]string{"day"})}),
+		model.RideFareEstimation{RideID: 1, CostEstimation: 14.5149, Breakdown: model.FareBreakdown{
+			Flag:            1.3,
+			Bands:           []model.BandCharge{{Name: "day", MovingKm: 2, MovingCost: 1.48}},
+			IdleHours:       1,
+			IdleCost:        11.73,
+			DiscardedPoints: 2,
+		}},
+		model.RideFareEstimation{RideID: 2, CostEstimation: 45.12, Breakdown: model.FareBreakdown{
+			Bands: []model.BandCharge{{Name: "day"}},
+		}})
+	if err != nil {
+		t.Fatalf("SinkWriter() returned error %v", err)
+	}
+
+	got, err := parquet.Read[parquetEstimation](bytes.NewReader(output.Bytes()), int64(output.Len()))
+	want := []parquetEstimation{
+		{RideID: 1, CostEstimation: 14.51, Flag: 1.3, MovingDayKm: 2, MovingDayCost: 1.48, IdleHours: 1, IdleCost: 11.73, DiscardedPoints: 2},
+		{RideID: 2, CostEstimation: 45.12},
+	}
+	if err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("Bad output, expected `%v`, got `%v`, %v", want, got, err)
+	}
+}
+
+func TestBreakdownParquetSink_missingBand(t *testing.T) {
+	err := writeToSink(NewParquetSink(bytes.NewBuffer(nil), SinkOptions{BreakdownHeader: model.BreakdownHeader([]string{"day"})}), sampleFareEstimation1)
+
+	if err == nil {
+		t.Errorf("SinkWriter() returned no error for an estimate without the bands of the header")
+	}
+}
+
+func TestParquetSink_status(t *testing.T) {
+	type parquetEstimation struct {
+		RideID         int64    `parquet:"id_ride"`
+		CostEstimation *float64 `parquet:"fare_estimate,optional"`
+		Status         string   `parquet:"status"`
+	}
+
+	output := bytes.NewBuffer(nil)
+
+	err := writeToSink(NewParquetSink(output, SinkOptions{Status: true}), sampleFareEstimation1, failedFareEstimation)
+	if err != nil {
+		t.Fatalf("SinkWriter() returned error %v", err)
+	}
+
+	got, err := parquet.Read[parquetEstimation](bytes.NewReader(output.Bytes()), int64(output.Len()))
+	cost := 14.51
+	want := []parquetEstimation{{RideID: 1, CostEstimation: &cost, Status: "ok"}, {RideID: 4, Status: "not_enough_segments"}}
+	if err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("Bad output, expected `%v`, got `%v`, %v", want, got, err)
+	}
+}
+
+func TestFailureSink(t *testing.T) {
+	output := bytes.NewBufferString("")
+
+	err := writeToSink(NewFailureSink(output), sampleFareEstimation1, failedFareEstimation)
+
+	want := "id_ride,error,points\n4,not_enough_segments,1\n"
+	if err != nil || output.String() != want {
+		t.Errorf("Bad output, expected `%v`, got `%v`, %v", want, output.String(), err)
+	}
+}
+
+func TestFlaggedRideSink(t *testing.T) {
+	auditor := audit.Auditor{
+		Charges: map[int64]model.ChargedFare{
+			1: {RideID: 1, DriverID: "driver-1", Charged: 14.51},
+			2: {RideID: 2, DriverID: "driver-2", Charged: 60},
+			4: {RideID: 4, DriverID: "driver-2", Charged: 60},
+		},
+		Tolerance: audit.Tolerance{Absolute: 1, Percentage: 10},
+	}
+
+	output := bytes.NewBufferString("")
+
+	err := writeToSink(NewFlaggedRideSink(output, auditor), append(fares, failedFareEstimation)...)
+
+	want := "id_ride,id_driver,fare_estimate,charged_fare,deviation,deviation_percentage\n" +
+		"2,driver-2,45.12,60.00,14.88,33.0\n"
+	if err != nil || output.String() != want {
+		t.Errorf("Bad output, expected `%v`, got `%v`, %v", want, output.String(), err)
+	}
+}
+
+func TestDriverReportSink(t *testing.T) {
+	report := audit.NewDriverReport(audit.Auditor{
+		Charges: map[int64]model.ChargedFare{
+			1: {RideID: 1, DriverID: "driver-1", Charged: 14.51},
+			2: {RideID: 2, DriverID: "driver-2", Charged: 60},
+			3: {RideID: 3, DriverID: "driver-1", Charged: 25.56},
+			4: {RideID: 4, DriverID: "driver-1", Charged: 25.56},
+		},
+		Tolerance: audit.Tolerance{Absolute: 1, Percentage: 10},
+	})
+
+	output := bytes.NewBufferString("")
+
+	err := writeToSink(NewDriverReportSink(output, report), append(fares, failedFareEstimation)...)
+
+	want := "rank,id_driver,rides,flagged_rides,flagged_ratio,total_overcharge,median_deviation\n" +
+		"1,driver-2,1,1,1.000,14.88,14.88\n" +
+		"2,driver-1,2,0,0.000,0.00,0.00\n"
+	if err != nil || output.String() != want {
+		t.Errorf("Bad output, expected `%v`, got `%v`, %v", want, output.String(), err)
+	}
+}
+
+// recordingSink records the estimates written into it, and fails the writes of the ride failRideID
+type recordingSink struct {
+	written    []model.RideFareEstimation
+	failRideID int64
+	closed     bool
+}
+
+var errRecordingSink = errors.New("recording sink failure")
+
+func (sink *recordingSink) Write(estimation model.RideFareEstimation) error {
+	if estimation.RideID == sink.failRideID {
+		return errRecordingSink
+	}
+	sink.written = append(sink.written, estimation)
+	return nil
+}
+
+func (sink *recordingSink) Close() error {
+	sink.closed = true
+	return nil
+}
+
+func TestMultiSink(t *testing.T) {
+	sinks := []*recordingSink{{}, {failRideID: 2}}
+
+	err := writeToSink(NewMultiSink(sinks[0], sinks[1]), fares...)
+
+	if !errors.Is(err, errRecordingSink) {
+		t.Errorf("SinkWriter() returned error %v, want %v", err, errRecordingSink)
+	}
+
+	// the estimates after a failed write are not written, but all the sinks are closed
+	want := [][]model.RideFareEstimation{{sampleFareEstimation1, sampleFareEstimation2}, {sampleFareEstimation1}}
+	for i, sink := range sinks {
+		if !reflect.DeepEqual(sink.written, want[i]) || !sink.closed {
+			t.Errorf("sink %d was written %v, closed: %v, want %v, closed", i, sink.written, sink.closed, want[i])
+		}
+	}
+}
+
+func TestSinkWriter_drainsAfterError(t *testing.T) {
+	sink := &recordingSink{failRideID: 1}
+
+	inputs := make(chan model.RideFareEstimation)
+	go func() {
+		for _, fare := range fares {
+			inputs <- fare
+		}
+		close(inputs)
+	}()
+
+	if written, err := SinkWriter(sink, inputs); written != 0 || !errors.Is(err, errRecordingSink) {
+		t.Errorf("SinkWriter() returned %d, %v, want 0, %v", written, err, errRecordingSink)
+	}
+	if len(sink.written) != 0 || !sink.closed {
+		t.Errorf("SinkWriter() wrote %v after an error, closed: %v", sink.written, sink.closed)
+	}
+}
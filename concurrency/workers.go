@@ -1,19 +1,16 @@
 package concurrency
 
 import (
-	"encoding/csv"
-	"fmt"
+	"context"
 	"harry-pap/beat_assignment/calculator"
 	"harry-pap/beat_assignment/model"
-	"io"
-	"log"
 	"sync"
 )
 
 // WorkerInput contains the input of the RunWorker function
 type WorkerInput struct {
-	Jobs    chan []calculator.RidePart
-	Results chan model.RideFareEstimation
+	Jobs    chan Job
+	Results chan Result
 	Done    chan interface{}
 	Wg      *sync.WaitGroup
 	Fun     func([]calculator.RidePart) (model.RideFareEstimation, error)
@@ -23,44 +20,50 @@ type WorkerInput struct {
 type ChannelCloserInput struct {
 	Count   int
 	Done    chan interface{}
-	Results chan model.RideFareEstimation
+	Results chan Result
 	Wg      *sync.WaitGroup
 }
 
-// RunWorker reads the ChannelCloserInput.Jobs channel
-// by invoking the ChannelCloserInput.Fun function on each message
+// RunWorker reads the WorkerInput.Jobs channel
+// by invoking the WorkerInput.Fun function on the parts of each Job, and pushing a Result with its sequence number,
+// even when the calculation fails, so that CollectResults can order them. The estimate of a failed calculation only
+// contains its model.RideFailure
+// When the context is cancelled, it stops taking jobs, while the result of the job in progress is still pushed
 // Upon completion, a message to WorkerInput.Done channel is sent, and WorkerInput.Wg.Done() is invoked
-func RunWorker(workerInput WorkerInput) {
+func RunWorker(ctx context.Context, workerInput WorkerInput) {
 	defer workerInput.Wg.Done()
 
-	for job := range workerInput.Jobs {
-		result, err := workerInput.Fun(job)
+	for ctx.Err() == nil {
+		var job Job
+		open := false
 
+		select {
+		case job, open = <-workerInput.Jobs:
+		case <-ctx.Done():
+		}
+		if !open {
+			break
+		}
+
+		estimation, err := workerInput.Fun(job.Parts)
 		if err != nil {
-			fmt.Println("Failed to calculate job because of error:", err)
-		} else {
-			workerInput.Results <- result
+			estimation = failedEstimation(job.Parts, err)
 		}
+
+		workerInput.Results <- Result{Sequence: job.Sequence, Estimation: estimation, Err: err}
 	}
 
 	workerInput.Done <- nil
 }
 
-// ResultWriter reads the RideFareEstimation channel, and writing each estimate into a *afero.File
-// Upon completion sync.WaitGroup.Done() is invoked
-func ResultWriter(writer io.Writer, inputs chan model.RideFareEstimation, wg *sync.WaitGroup) {
-	defer wg.Done()
-
-	csvWriter := csv.NewWriter(writer)
-
-	for input := range inputs {
-		err := csvWriter.Write(input.ToStringSlice())
-
-		if err != nil {
-			log.Fatal("Cannot Write to writer:", err)
-		}
+// failedEstimation returns the estimate of a ride whose fare could not be calculated because of err
+func failedEstimation(parts []calculator.RidePart, err error) model.RideFareEstimation {
+	failure := model.RideFailure{Kind: calculator.FailureKind(err), Points: len(parts)}
+	if len(parts) > 0 {
+		failure.RideID = parts[0].RideID
 	}
-	csvWriter.Flush()
+
+	return model.RideFareEstimation{RideID: failure.RideID, Failure: &failure}
 }
 
 // CloseResultChannelWhenWorkersDone listens to the ChannelCloserInput.Done channel, and for closing ChannelCloserInput.Done and
@@ -1,9 +1,11 @@
 package concurrency
 
 import (
-	"bytes"
+	"context"
+	"errors"
 	"harry-pap/beat_assignment/calculator"
 	"harry-pap/beat_assignment/model"
+	"reflect"
 	"sync"
 	"testing"
 	"time"
@@ -20,13 +22,15 @@ var (
 	sampleFareEstimation2 = model.RideFareEstimation{RideID: 2, CostEstimation: 45.12}
 	sampleFareEstimation3 = model.RideFareEstimation{RideID: 3, CostEstimation: 25.56}
 	fares                 = []model.RideFareEstimation{sampleFareEstimation1, sampleFareEstimation2, sampleFareEstimation3}
+	errCalculation        = errors.New("calculation failure")
+	failedFareEstimation  = model.RideFareEstimation{RideID: 4, Failure: &model.RideFailure{RideID: 4, Kind: "not_enough_segments", Points: 1}}
 )
 
 func TestWorker(t *testing.T) {
 
 	type args struct {
 		input          WorkerInput
-		expectedPushes []model.RideFareEstimation
+		expectedPushes []Result
 	}
 	tests := []struct {
 		name string
@@ -36,36 +40,55 @@ func TestWorker(t *testing.T) {
 		{
 			"for a single ride",
 			args{WorkerInput{
-				make(chan []calculator.RidePart, 10),
-				make(chan model.RideFareEstimation, 10),
+				make(chan Job, 10),
+				make(chan Result, 10),
 				make(chan interface{}, 10),
 				newGroup(),
 				func(parts []calculator.RidePart) (model.RideFareEstimation, error) {
 					return sampleFareEstimation1, nil
 				}},
-				[]model.RideFareEstimation{sampleFareEstimation1},
+				[]Result{{Sequence: 0, Estimation: sampleFareEstimation1}},
 			},
 			func(args args) {
-				args.input.Jobs <- []calculator.RidePart{}
+				args.input.Jobs <- Job{Sequence: 0, Parts: []calculator.RidePart{}}
 				close(args.input.Jobs)
 			},
 		},
 		{
 			"for multiple rides",
 			args{WorkerInput{
-				make(chan []calculator.RidePart, 10),
-				make(chan model.RideFareEstimation, 10),
+				make(chan Job, 10),
+				make(chan Result, 10),
 				make(chan interface{}, 10),
 				newGroup(),
 				func(parts []calculator.RidePart) (model.RideFareEstimation, error) {
 					return fares[parts[0].RideID], nil
 				}},
-				[]model.RideFareEstimation{sampleFareEstimation1, sampleFareEstimation2, sampleFareEstimation3},
+				[]Result{{0, sampleFareEstimation1, nil}, {1, sampleFareEstimation2, nil}, {2, sampleFareEstimation3, nil}},
 			},
 			func(args args) {
-				args.input.Jobs <- []calculator.RidePart{{0, calculator.Coordinate{0, 0}, 0}}
-				args.input.Jobs <- []calculator.RidePart{{1, calculator.Coordinate{0, 0}, 0}}
-				args.input.Jobs <- []calculator.RidePart{{2, calculator.Coordinate{0, 0}, 0}}
+				args.input.Jobs <- Job{Sequence: 0, Parts: []calculator.RidePart{{RideID: 0}}}
+				args.input.Jobs <- Job{Sequence: 1, Parts: []calculator.RidePart{{RideID: 1}}}
+				args.input.Jobs <- Job{Sequence: 2, Parts: []calculator.RidePart{{RideID: 2}}}
+				close(args.input.Jobs)
+			},
+		},
+		{
+			"for a failed ride",
+			args{WorkerInput{
+				make(chan Job, 10),
+				make(chan Result, 10),
+				make(chan interface{}, 10),
+				newGroup(),
+				func(parts []calculator.RidePart) (model.RideFareEstimation, error) {
+					return model.RideFareEstimation{}, errCalculation
+				}},
+				[]Result{{Sequence: 4, Err: errCalculation, Estimation: model.RideFareEstimation{
+					RideID: 5, Failure: &model.RideFailure{RideID: 5, Kind: "calculation_failed", Points: 1},
+				}}},
+			},
+			func(args args) {
+				args.input.Jobs <- Job{Sequence: 4, Parts: []calculator.RidePart{{RideID: 5}}}
 				close(args.input.Jobs)
 			},
 		},
@@ -75,11 +98,11 @@ func TestWorker(t *testing.T) {
 
 			tt.run(tt.args)
 
-			RunWorker(tt.args.input)
+			RunWorker(context.Background(), tt.args.input)
 
-			for _, fareEstimation := range tt.args.expectedPushes {
-				if res := <-tt.args.input.Results; res != fareEstimation {
-					t.Errorf("RunWorker pushed unexpected result channel = %v, want %v", res, fareEstimation)
+			for _, result := range tt.args.expectedPushes {
+				if res := <-tt.args.input.Results; !reflect.DeepEqual(res, result) {
+					t.Errorf("RunWorker pushed unexpected result channel = %v, want %v", res, result)
 				}
 			}
 
@@ -101,40 +124,25 @@ func TestWorker(t *testing.T) {
 	}
 }
 
-func TestResultWriter(t *testing.T) {
-	type args struct {
-		inputs chan model.RideFareEstimation
-		wg     *sync.WaitGroup
-	}
-	tests := []struct {
-		name string
-		args args
-		want string
-	}{
-		{"with 2 rides",
-			args{
-				make(chan model.RideFareEstimation, 10),
-				newGroup(),
-			},
-			"1,14.51\n2,45.12\n",
+func TestWorker_cancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	input := WorkerInput{
+		Jobs:    make(chan Job, 10),
+		Results: make(chan Result, 10),
+		Done:    make(chan interface{}, 10),
+		Wg:      newGroup(),
+		Fun: func(parts []calculator.RidePart) (model.RideFareEstimation, error) {
+			return sampleFareEstimation1, nil
 		},
 	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-
-			tt.args.inputs <- sampleFareEstimation1
-			tt.args.inputs <- sampleFareEstimation2
-
-			close(tt.args.inputs)
+	input.Jobs <- Job{Sequence: 0, Parts: []calculator.RidePart{{RideID: 1}}}
 
-			output := bytes.NewBufferString("")
+	RunWorker(ctx, input)
 
-			ResultWriter(output, tt.args.inputs, tt.args.wg)
-
-			if output.String() != tt.want {
-				t.Errorf("Bad output, expected `%v`, got `%v`", tt.want, output.String())
-			}
-		})
+	if len(input.Results) != 0 || len(input.Done) != 1 {
+		t.Errorf("RunWorker pushed %d results after cancellation, and %d messages to Done, want 0 and 1", len(input.Results), len(input.Done))
 	}
 }
 
@@ -153,7 +161,7 @@ func TestChannelCloser(t *testing.T) {
 				ChannelCloserInput{
 					5,
 					make(chan interface{}, 5),
-					make(chan model.RideFareEstimation, 5),
+					make(chan Result, 5),
 					newGroup(),
 				},
 			},
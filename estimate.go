@@ -0,0 +1,331 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"harry-pap/beat_assignment/audit"
+	"harry-pap/beat_assignment/calculator"
+	"harry-pap/beat_assignment/compression"
+	"harry-pap/beat_assignment/concurrency"
+	"harry-pap/beat_assignment/model"
+	"harry-pap/beat_assignment/parser"
+	"io"
+	"os"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// defaultMaxRejects is the default -max-rejects of the estimate command, so that a few malformed lines are skipped,
+// while an input that is mostly malformed, e.g. read with the wrong -columns, is aborted early
+const defaultMaxRejects = 100
+
+// estimateUsage is printed before the flags of the estimate command, by -h and on invalid arguments
+const estimateUsage = `Usage: fare-calculator [estimate] [flags] [source [target]]
+       fare-calculator validate|explain|compare|geojson [flags] ...
+
+Estimates the fare of each ride of the source, and writes the estimates into the target.
+The source and target can be given as arguments, or with -input and -output, and can be - for the standard streams.
+
+The other commands, whose flags are listed by their own -h, are:
+  validate  checks the rides of a source without pricing them
+  explain   prints how the fare of a ride is calculated, segment by segment
+  compare   compares the estimates of two targets
+  geojson   writes rides as GeoJSON, to be viewed on a map
+
+Flags:
+`
+
+// runEstimate runs the estimate command, and is responsible for parsing the arguments, launching the involved
+// goroutines, opening the involved files and wiring the needed functions
+// When the context is cancelled, the input stops being read, and the estimates already calculated are written
+func runEstimate(ctx context.Context, args []string) error {
+	now := time.Now().UTC()
+
+	flags := flag.NewFlagSet("estimate", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), estimateUsage)
+		flags.PrintDefaults()
+	}
+	inputPath := flags.String("input", "", "source to read the rides from, - for the standard input, instead of the first argument")
+	outputPath := flags.String("output", "", "target to write the estimates to, - for the standard output, instead of the second argument")
+	workers := flags.Int("workers", runtime.NumCPU(), "number of workers calculating the fares")
+	jobsBuffer := flags.Int("jobs-buffer", 100, "number of rides buffered between the input and the workers")
+	resultsBuffer := flags.Int("results-buffer", 0, "number of estimates buffered between the workers and the outputs, 0 for 20 per worker")
+	breakdown := flags.Bool("breakdown", false, "write a header, and the breakdown of each fare as extra columns")
+	status := flags.Bool("status", false, "write the rides whose fare could not be calculated too, with a status column of ok or the kind of error")
+	failuresPath := flags.String("failures", "", "CSV to write the rides whose fare could not be calculated to, with the kind of error and their number of points")
+	chargedPath := flags.String("charged", "", "CSV of (id_ride, charged_fare, id_driver) to compare the estimates with")
+	flaggedPath := flags.String("flagged", "", "CSV to write the rides overcharged by more than the tolerance to, required with -charged")
+	driversPath := flags.String("drivers", "", "CSV to write the drivers ranked by overcharge to, used with -charged")
+	rejectsPath := flags.String("rejects", "", "CSV to write the rejected input lines to, with their line number and reason")
+	var source sourceFlags
+	source.register(flags, defaultMaxRejects)
+	var outputFormat parser.Format
+	flags.Var(&outputFormat, "output-format", "format of the target: csv, jsonl, parquet, or auto to detect it by the file extension")
+	var fare fareFlags
+	fare.register(flags)
+	ordered := flags.Bool("ordered", false, "write the estimates in the order of the rides in the input, instead of the order they are calculated in")
+	orderBuffer := flags.Int("order-buffer", 1000, "number of rides in progress at once with -ordered, bounding the estimates buffered, 0 for no limit")
+	var alsoOutputs pathList
+	flags.Var(&alsoOutputs, "also-output", "another target to write the estimates to, in the format of its extension, can be repeated")
+	toleranceAbsolute := flags.Float64("tolerance-abs", 1.0, "amount a charged fare can exceed its estimate by, before it is flagged")
+	tolerancePercentage := flags.Float64("tolerance-pct", 10, "percentage of its estimate a charged fare can exceed it by, before it is flagged")
+	if err := flags.Parse(args); err != nil {
+		return fmt.Errorf("invalid arguments: %w", err)
+	}
+
+	argsErr := resolvePositionals(flags.Args(), positional{name: "source", value: inputPath}, positional{name: "target", value: outputPath})
+	if argsErr == nil {
+		argsErr = validateArguments(flags, append(append(source.numberArguments(), fare.numberArguments()...), []numberArgument{
+			{"workers", float64(*workers), 1},
+			{"jobs-buffer", float64(*jobsBuffer), 0},
+			{"results-buffer", float64(*resultsBuffer), 0},
+			{"order-buffer", float64(*orderBuffer), 0},
+			{"tolerance-abs", *toleranceAbsolute, 0},
+			{"tolerance-pct", *tolerancePercentage, 0},
+		}...), []string{*inputPath}, *outputPath)
+	}
+	if argsErr == nil && *chargedPath != "" {
+		argsErr = validateReports(*inputPath, *outputPath, reportPath{"flagged", *flaggedPath, true}, reportPath{"drivers", *driversPath, false})
+	}
+	if argsErr != nil {
+		flags.Usage()
+		return fmt.Errorf("invalid arguments: %w", argsErr)
+	}
+
+	if *resultsBuffer == 0 {
+		*resultsBuffer = *workers * 20
+	}
+
+	tariff, err := fare.tariff()
+	if err != nil {
+		return err
+	}
+
+	var charges map[int64]model.ChargedFare
+	if *chargedPath != "" {
+		if charges, err = readCharges(*chargedPath); err != nil {
+			return err
+		}
+	}
+
+	// every file is opened before the goroutines are launched, and closed together once they are done, or on an error
+	var files openedFiles
+
+	parserOptions := source.options()
+	if *rejectsPath != "" {
+		rejectsFile, err := os.Create(*rejectsPath)
+		if err != nil {
+			return fmt.Errorf("cannot create rejects: %w", err)
+		}
+		files = append(files, rejectsFile)
+
+		parserOptions.Rejects = rejectsFile
+	}
+
+	rideSource, err := source.open(ctx, *inputPath, parserOptions)
+	if err != nil {
+		return errors.Join(fmt.Errorf("cannot open source: %w", err), files.Close())
+	}
+
+	sinks, err := createSinks(&files, *outputPath, outputFormat, alsoOutputs, sinkOptions(tariff, *breakdown, *status))
+	if err == nil && *failuresPath != "" {
+		var failuresFile *os.File
+		if failuresFile, err = os.Create(*failuresPath); err == nil {
+			files = append(files, failuresFile)
+			sinks = append(sinks, concurrency.NewFailureSink(failuresFile))
+		}
+	}
+	if err == nil && charges != nil {
+		auditor := audit.Auditor{Charges: charges, Tolerance: audit.Tolerance{Absolute: *toleranceAbsolute, Percentage: *tolerancePercentage}}
+
+		var flaggedFile, driversFile *os.File
+		if flaggedFile, err = os.Create(*flaggedPath); err == nil {
+			files = append(files, flaggedFile)
+			sinks = append(sinks, concurrency.NewFlaggedRideSink(flaggedFile, auditor))
+		}
+		if err == nil && *driversPath != "" {
+			if driversFile, err = os.Create(*driversPath); err == nil {
+				files = append(files, driversFile)
+				sinks = append(sinks, concurrency.NewDriverReportSink(driversFile, audit.NewDriverReport(auditor)))
+			}
+		}
+	}
+	if err != nil {
+		return errors.Join(fmt.Errorf("cannot create output: %w", err), rideSource.Close(), files.Close())
+	}
+
+	var wg sync.WaitGroup
+
+	rides := make(chan []calculator.RidePart, *jobsBuffer)
+	jobs := make(chan concurrency.Job, *jobsBuffer)
+	results := make(chan concurrency.Result, *resultsBuffer)
+	estimates := make(chan model.RideFareEstimation, *resultsBuffer)
+	done := make(chan interface{}, *workers)
+
+	// with -ordered, the jobs in progress are bounded, so that the results waiting for the ones before them are too
+	var slots chan struct{}
+	if *ordered {
+		slots = concurrency.NewSlots(*orderBuffer)
+	}
+
+	sequencerInput := concurrency.SequencerInput{Rides: rides, Jobs: jobs, Slots: slots, Wg: &wg}
+
+	launch(func() { concurrency.SequenceJobs(ctx, sequencerInput) }, &wg)
+
+	channelCloserInput := concurrency.ChannelCloserInput{Count: *workers, Done: done, Results: results, Wg: &wg}
+
+	launch(func() { concurrency.CloseResultChannelWhenWorkersDone(channelCloserInput) }, &wg)
+
+	collectorInput := concurrency.CollectorInput{Results: results, Estimates: estimates, Ordered: *ordered, Slots: slots, Wg: &wg}
+
+	launch(func() { concurrency.CollectResults(collectorInput) }, &wg)
+
+	for w := 1; w <= *workers; w++ {
+		workerInput := concurrency.WorkerInput{Jobs: jobs, Results: results, Done: done, Wg: &wg, Fun: func(parts []calculator.RidePart) (model.RideFareEstimation, error) {
+			parts, reordered := fare.reorderParts(parts)
+
+			estimation, err := calculator.CalculateFareForRide(parts, tariff)
+			estimation.Breakdown.ReorderedPoints = reordered
+
+			return estimation, err
+		}}
+		launch(func() { concurrency.RunWorker(ctx, workerInput) }, &wg)
+	}
+
+	summary := &runSummary{}
+	sinks = append(sinks, summary)
+
+	var written int
+	var sinkErr error
+	launch(func() {
+		defer wg.Done()
+		written, sinkErr = concurrency.SinkWriter(concurrency.NewMultiSink(sinks...), estimates)
+	}, &wg)
+
+	parseErr := parser.PushRides(ctx, rideSource, rides)
+	if closeErr := rideSource.Close(); parseErr == nil {
+		parseErr = closeErr
+	}
+
+	close(rides)
+
+	wg.Wait()
+
+	closeErr := files.Close()
+
+	fmt.Fprintln(os.Stderr, "Time elapsed: ", time.Since(now))
+
+	summary.print(os.Stderr, *fare.reorder)
+
+	if errors.Is(parseErr, context.Canceled) {
+		fmt.Fprintf(os.Stderr, "Interrupted: %v, the %d estimates calculated were written\n", parseErr, written)
+		parseErr = fmt.Errorf("interrupted: %w", parseErr)
+	}
+
+	return errors.Join(parseErr, sinkErr, closeErr)
+}
+
+// runSummary is a concurrency.ResultSink counting the failed rides, and the points moved by -reorder in the estimated
+// ones, which are summarized once the run is done
+type runSummary struct {
+	failed          int
+	reorderedRides  int
+	reorderedPoints int
+}
+
+func (summary *runSummary) Write(estimation model.RideFareEstimation) error {
+	if estimation.Failure != nil {
+		summary.failed++
+	}
+	if reordered := estimation.Breakdown.ReorderedPoints; reordered > 0 {
+		summary.reorderedRides++
+		summary.reorderedPoints += reordered
+	}
+	return nil
+}
+
+func (summary *runSummary) Close() error {
+	return nil
+}
+
+// print writes the summary into the writer, with the count of reordered points when reorder is set
+func (summary *runSummary) print(writer io.Writer, reorder bool) {
+	if reorder {
+		fmt.Fprintf(writer, "Reordered %d points in %d rides, -breakdown writes the count of each ride\n", summary.reorderedPoints, summary.reorderedRides)
+	}
+	if summary.failed > 0 {
+		fmt.Fprintf(writer, "The fare of %d rides could not be calculated, -failures and -status write their errors\n", summary.failed)
+	}
+}
+
+// sinkOptions returns the concurrency.SinkOptions of the estimate targets, with the bands of the tariff in the
+// breakdown header
+func sinkOptions(tariff calculator.Tariff, breakdown bool, status bool) concurrency.SinkOptions {
+	options := concurrency.SinkOptions{Status: status}
+	if !breakdown {
+		return options
+	}
+
+	bandNames := make([]string, 0, len(tariff.Bands))
+	for _, band := range tariff.Bands {
+		bandNames = append(bandNames, band.Name)
+	}
+	options.BreakdownHeader = model.BreakdownHeader(bandNames)
+	return options
+}
+
+// createSinks creates the target, in the given format, and the -also-output targets, in the format of their
+// extension, adding them to the files, and returns their ResultSinks
+func createSinks(files *openedFiles, path string, format parser.Format, alsoOutputs pathList, options concurrency.SinkOptions) ([]concurrency.ResultSink, error) {
+	sinks := make([]concurrency.ResultSink, 0, len(alsoOutputs)+1)
+
+	for i, target := range append([]string{path}, alsoOutputs...) {
+		if i > 0 {
+			format = parser.FormatAuto
+		}
+
+		file, sink, err := createSink(target, format.Resolve(target), options)
+		if err != nil {
+			return nil, err
+		}
+		*files = append(*files, file)
+		sinks = append(sinks, sink)
+	}
+	return sinks, nil
+}
+
+// readCharges reads the charged fares of the CSV at path
+func readCharges(path string) (map[int64]model.ChargedFare, error) {
+	file, err := compression.OpenInput(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read charged fares: %w", err)
+	}
+	defer file.Close()
+
+	charges, err := parser.ParseChargedFaresCSV(file)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read charged fares %s: %w", path, err)
+	}
+	return charges, nil
+}
+
+// createSink creates the target, and returns it with the ResultSink writing the estimates into it in the given format
+func createSink(path string, format parser.Format, options concurrency.SinkOptions) (io.Closer, concurrency.ResultSink, error) {
+	file, err := compression.CreateOutput(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch format {
+	case parser.FormatJSONL:
+		return file, concurrency.NewJSONSink(file, options), nil
+	case parser.FormatParquet:
+		return file, concurrency.NewParquetSink(file, options), nil
+	default:
+		return file, concurrency.NewCSVSink(file, options), nil
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"harry-pap/beat_assignment/calculator"
+	"harry-pap/beat_assignment/compression"
+	"harry-pap/beat_assignment/parser"
+	"io"
+	"strconv"
+)
+
+// explainUsage is printed before the flags of the explain command, by -h and on invalid arguments
+const explainUsage = `Usage: fare-calculator explain [flags] ride_id [source]
+
+Prints how the fare of the first ride of the source with the given id is calculated, as a table or as JSON: each of
+its points, whether it is kept, and the speed it was rejected at, followed by its valid segments, with their distance,
+speed, state, bands and fare, and the amounts added to the fare.
+
+Flags:
+`
+
+// runExplain runs the explain command, reading the source until the ride is found
+func runExplain(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("explain", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), explainUsage)
+		flags.PrintDefaults()
+	}
+	inputPath := flags.String("input", "", "source to read the rides from, - for the standard input, instead of the second argument")
+	outputPath := flags.String("output", compression.StdStream, "file to write the trace to, - for the standard output")
+	asJSON := flags.Bool("json", false, "write the trace as a JSON object, instead of a table")
+	var fare fareFlags
+	fare.register(flags)
+	var source sourceFlags
+	source.register(flags, -1)
+	if err := flags.Parse(args); err != nil {
+		return fmt.Errorf("invalid arguments: %w", err)
+	}
+
+	var rideText string
+	argsErr := resolvePositionals(flags.Args(), positional{name: "ride_id", value: &rideText}, positional{name: "source", value: inputPath})
+	rideID, idErr := strconv.ParseInt(rideText, 10, 64)
+	if argsErr == nil && idErr != nil {
+		argsErr = fmt.Errorf("invalid ride_id %q", rideText)
+	}
+	if argsErr == nil {
+		argsErr = validateArguments(flags, append(source.numberArguments(), fare.numberArguments()...),
+			[]string{*inputPath}, *outputPath)
+	}
+	if argsErr != nil {
+		flags.Usage()
+		return fmt.Errorf("invalid arguments: %w", argsErr)
+	}
+
+	tariff, err := fare.tariff()
+	if err != nil {
+		return err
+	}
+
+	rides, err := source.open(ctx, *inputPath, source.options())
+	if err != nil {
+		return err
+	}
+
+	parts, err := findRide(ctx, rides, rideID)
+	if closeErr := rides.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	parts, _ = fare.reorderParts(parts)
+	trace := calculator.TraceRide(parts, tariff)
+
+	outputFile, err := compression.CreateOutput(*outputPath)
+	if err != nil {
+		return err
+	}
+
+	if *asJSON {
+		err = trace.WriteJSON(outputFile)
+	} else {
+		err = trace.WriteTable(outputFile)
+	}
+	return errors.Join(err, outputFile.Close())
+}
+
+// findRide returns the parts of the first ride of the source with the given id
+func findRide(ctx context.Context, rides parser.RideSource, rideID int64) ([]calculator.RidePart, error) {
+	for ctx.Err() == nil {
+		parts, err := rides.Next()
+		if errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("ride %d is not in the source", rideID)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if parts[0].RideID == rideID {
+			return parts, nil
+		}
+	}
+	return nil, ctx.Err()
+}
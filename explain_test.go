@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"harry-pap/beat_assignment/calculator"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func Test_explain(t *testing.T) {
+	dir := t.TempDir()
+	outputTable := filepath.Join(dir, "trace.txt")
+	outputJSON := filepath.Join(dir, "trace.json")
+
+	if err := run(context.Background(), []string{"explain", "-output", outputTable, "1", "testdata/sample.csv"}); err != nil {
+		t.Fatalf("run() returned error %v", err)
+	}
+	table, _ := os.ReadFile(outputTable)
+	for _, want := range []string{"Ride 1: 4 points, 3 valid segments\n", "3     2-3  3.413  2.0000   1.71    idle        day  23.80", "fare estimate 27.39\n"} {
+		if !strings.Contains(string(table), want) {
+			t.Errorf("Bad output, expected it to contain `%v`, got `%v`", want, string(table))
+		}
+	}
+
+	if err := run(context.Background(), []string{"explain", "-json", "-output", outputJSON, "-input", "testdata/sample_failed.csv", "2"}); err != nil {
+		t.Fatalf("run() returned error %v", err)
+	}
+	var trace calculator.RideTrace
+	bytes, _ := os.ReadFile(outputJSON)
+	if err := json.Unmarshal(bytes, &trace); err != nil || trace.RideID != 2 || len(trace.Points) != 1 || trace.Failure != "not_enough_segments" {
+		t.Errorf("Bad output `%v`, %v", string(bytes), err)
+	}
+}
+
+func Test_explain_arguments(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{"missing ride", []string{"explain"}, "missing ride_id"},
+		{"missing source", []string{"explain", "1"}, "missing source"},
+		{"invalid ride id", []string{"explain", "one", "testdata/sample.csv"}, `invalid ride_id "one"`},
+		{"ride not in the source", []string{"explain", "2", "testdata/sample.csv"}, "ride 2 is not in the source"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := run(context.Background(), tt.args); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("run() returned error %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"harry-pap/beat_assignment/calculator"
+	"harry-pap/beat_assignment/compression"
+	"harry-pap/beat_assignment/geojson"
+	"io"
+	"sort"
+)
+
+// geojsonUsage is printed before the flags of the geojson command, by -h and on invalid arguments
+const geojsonUsage = `Usage: fare-calculator geojson [flags] -rides ride_id[,ride_id...] [source [target]]
+
+Writes a GeoJSON FeatureCollection of the rides of the source with the given ids, to be viewed on a map: the raw
+points, with the rejected ones highlighted, the path of the kept points, and each valid segment, with its speed, state
+and fare. The target is the standard output, unless it is given.
+
+Flags:
+`
+
+// runGeoJSON runs the geojson command, reading the source until all the rides are found
+func runGeoJSON(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("geojson", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), geojsonUsage)
+		flags.PrintDefaults()
+	}
+	inputPath := flags.String("input", "", "source to read the rides from, - for the standard input, instead of the first argument")
+	outputPath := flags.String("output", "", "target to write the GeoJSON to, - for the standard output(default), instead of the second argument")
+	var rideIDs rideIDList
+	flags.Var(&rideIDs, "rides", "ids of the rides to export, separated by commas, can be repeated")
+	var fare fareFlags
+	fare.register(flags)
+	var source sourceFlags
+	source.register(flags, -1)
+	if err := flags.Parse(args); err != nil {
+		return fmt.Errorf("invalid arguments: %w", err)
+	}
+
+	argsErr := resolvePositionals(flags.Args(), positional{name: "source", value: inputPath}, positional{name: "target", value: outputPath, optional: true})
+	if *outputPath == "" {
+		*outputPath = compression.StdStream
+	}
+	if argsErr == nil && len(rideIDs) == 0 {
+		argsErr = errors.New("missing -rides")
+	}
+	if argsErr == nil {
+		argsErr = validateArguments(flags, append(source.numberArguments(), fare.numberArguments()...),
+			[]string{*inputPath}, *outputPath)
+	}
+	if argsErr != nil {
+		flags.Usage()
+		return fmt.Errorf("invalid arguments: %w", argsErr)
+	}
+
+	tariff, err := fare.tariff()
+	if err != nil {
+		return err
+	}
+
+	rides, err := source.open(ctx, *inputPath, source.options())
+	if err != nil {
+		return err
+	}
+
+	// the first ride of each id is exported, in the order of the source
+	missing := make(map[int64]bool, len(rideIDs))
+	for _, id := range rideIDs {
+		missing[id] = true
+	}
+
+	traces := make([]calculator.RideTrace, 0, len(missing))
+	for len(missing) > 0 && err == nil {
+		if err = ctx.Err(); err != nil {
+			break
+		}
+
+		var parts []calculator.RidePart
+		if parts, err = rides.Next(); err != nil {
+			break
+		}
+		if !missing[parts[0].RideID] {
+			continue
+		}
+		delete(missing, parts[0].RideID)
+
+		parts, _ = fare.reorderParts(parts)
+		traces = append(traces, calculator.TraceRide(parts, tariff))
+	}
+	if err == io.EOF {
+		err = nil
+	}
+	if closeErr := rides.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	outputFile, err := compression.CreateOutput(*outputPath)
+	if err != nil {
+		return err
+	}
+	err = errors.Join(geojson.Write(outputFile, traces...), outputFile.Close())
+
+	if err == nil && len(missing) > 0 {
+		ids := make([]int64, 0, len(missing))
+		for id := range missing {
+			ids = append(ids, id)
+		}
+		sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+		err = fmt.Errorf("rides %v are not in the source", ids)
+	}
+	return err
+}
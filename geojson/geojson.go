@@ -0,0 +1,140 @@
+package geojson
+
+import (
+	"encoding/json"
+	"harry-pap/beat_assignment/calculator"
+	"io"
+	"time"
+)
+
+// The kinds of the features of a ride, in their kind property
+const (
+	KindPoint   = "point"
+	KindPath    = "path"
+	KindSegment = "segment"
+)
+
+// The colors of the features, as simplestyle properties, which map viewers such as geojson.io draw
+const (
+	colorKept     = "#1f77b4"
+	colorRejected = "#d62728"
+	colorPath     = "#555555"
+	colorMoving   = "#2ca02c"
+	colorIdle     = "#ff7f0e"
+)
+
+// FeatureCollection is a GeoJSON FeatureCollection
+type FeatureCollection struct {
+	Type     string    `json:"type"`
+	Features []Feature `json:"features"`
+}
+
+// Feature is a GeoJSON Feature, with a geometry and its properties
+type Feature struct {
+	Type       string                 `json:"type"`
+	Geometry   Geometry               `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+// Geometry is a GeoJSON Point, whose Coordinates are a [longitude, latitude] pair, or a LineString, whose Coordinates
+// are a list of them
+type Geometry struct {
+	Type        string      `json:"type"`
+	Coordinates interface{} `json:"coordinates"`
+}
+
+// NewFeatureCollection returns the FeatureCollection of the features of each ride, in order
+func NewFeatureCollection(traces ...calculator.RideTrace) FeatureCollection {
+	collection := FeatureCollection{Type: "FeatureCollection", Features: make([]Feature, 0)}
+
+	for _, trace := range traces {
+		collection.Features = append(collection.Features, RideFeatures(trace)...)
+	}
+	return collection
+}
+
+// RideFeatures returns the features of a ride, from its calculator.RideTrace: a Point per raw point, with the rejected
+// ones highlighted, a LineString of the path of the kept points, unless there is a single one, and a LineString per
+// valid segment, with its speed, state and fare. Each feature has the id_ride and kind properties
+func RideFeatures(trace calculator.RideTrace) []Feature {
+	result := make([]Feature, 0, 2*len(trace.Points)+1)
+
+	path := make([][]float64, 0, len(trace.Points))
+	for _, point := range trace.Points {
+		position := []float64{point.Longitude, point.Latitude}
+
+		properties := map[string]interface{}{
+			"id_ride":   trace.RideID,
+			"kind":      KindPoint,
+			"index":     point.Index,
+			"timestamp": point.Timestamp.Format(time.RFC3339Nano),
+			"kept":      point.Kept,
+			"speed_kmh": point.Speed,
+		}
+		if point.Kept {
+			properties["marker-color"] = colorKept
+			path = append(path, position)
+		} else {
+			properties["reason"] = point.Reason
+			properties["marker-color"] = colorRejected
+			properties["marker-size"] = "large"
+		}
+		result = append(result, Feature{Type: "Feature", Geometry: Geometry{Type: "Point", Coordinates: position}, Properties: properties})
+	}
+
+	if len(path) > 1 {
+		properties := map[string]interface{}{
+			"id_ride":       trace.RideID,
+			"kind":          KindPath,
+			"points":        len(trace.Points),
+			"kept_points":   len(path),
+			"fare_estimate": trace.FareEstimate,
+			"stroke":        colorPath,
+		}
+		if trace.Failure != "" {
+			properties["failure"] = trace.Failure
+		}
+		result = append(result, Feature{Type: "Feature", Geometry: Geometry{Type: "LineString", Coordinates: path}, Properties: properties})
+	}
+
+	for _, segment := range trace.Segments {
+		from, to := trace.Points[segment.From], trace.Points[segment.To]
+
+		bands := make([]string, 0, len(segment.Charges))
+		for _, charge := range segment.Charges {
+			bands = append(bands, charge.Band)
+		}
+
+		stroke := colorMoving
+		if segment.State == calculator.StateIdle {
+			stroke = colorIdle
+		}
+
+		result = append(result, Feature{
+			Type: "Feature",
+			Geometry: Geometry{Type: "LineString", Coordinates: [][]float64{
+				{from.Longitude, from.Latitude}, {to.Longitude, to.Latitude},
+			}},
+			Properties: map[string]interface{}{
+				"id_ride":   trace.RideID,
+				"kind":      KindSegment,
+				"from":      segment.From,
+				"to":        segment.To,
+				"km":        segment.Km,
+				"hours":     segment.Hours,
+				"speed_kmh": segment.Speed,
+				"state":     segment.State,
+				"bands":     bands,
+				"fare":      segment.Fare,
+				"stroke":    stroke,
+			},
+		})
+	}
+
+	return result
+}
+
+// Write writes the FeatureCollection of the rides into the writer, as a JSON object
+func Write(writer io.Writer, traces ...calculator.RideTrace) error {
+	return json.NewEncoder(writer).Encode(NewFeatureCollection(traces...))
+}
@@ -0,0 +1,111 @@
+package geojson
+
+import (
+	"bytes"
+	"encoding/json"
+	"harry-pap/beat_assignment/calculator"
+	"reflect"
+	"testing"
+	"time"
+)
+
+var (
+	start  = time.Date(2018, 12, 12, 10, 0, 0, 0, time.UTC).UnixNano()
+	minute = time.Minute.Nanoseconds()
+	hour   = time.Hour.Nanoseconds()
+)
+
+func TestRideFeatures(t *testing.T) {
+	tests := []struct {
+		name      string
+		parts     []calculator.RidePart
+		wantKinds []string
+		wantKept  []bool
+	}{
+		{
+			"ride with a rejected point",
+			[]calculator.RidePart{
+				{RideID: 1, Coordinate: calculator.Coordinate{Latitude: 49.143352, Longitude: 3.519707}, Timestamp: start},
+				{RideID: 1, Coordinate: calculator.Coordinate{Latitude: 51.365184, Longitude: -2.388245}, Timestamp: start + minute},
+				{RideID: 1, Coordinate: calculator.Coordinate{Latitude: 49.150684, Longitude: 3.532212}, Timestamp: start + 3*minute},
+				{RideID: 1, Coordinate: calculator.Coordinate{Latitude: 49.150684, Longitude: 3.532212}, Timestamp: start + hour},
+			},
+			[]string{KindPoint, KindPoint, KindPoint, KindPoint, KindPath, KindSegment, KindSegment},
+			[]bool{true, false, true, true},
+		},
+		{
+			"ride of a single point has no path",
+			[]calculator.RidePart{{RideID: 2, Coordinate: calculator.Coordinate{Latitude: 49.143352, Longitude: 3.519707}, Timestamp: start}},
+			[]string{KindPoint},
+			[]bool{true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := RideFeatures(calculator.TraceRide(tt.parts, calculator.DefaultTariff))
+
+			kinds := make([]string, 0, len(got))
+			kept := make([]bool, 0, len(tt.parts))
+			for _, feature := range got {
+				kinds = append(kinds, feature.Properties["kind"].(string))
+				if feature.Properties["kind"] == KindPoint {
+					kept = append(kept, feature.Properties["kept"].(bool))
+				}
+			}
+			if !reflect.DeepEqual(kinds, tt.wantKinds) || !reflect.DeepEqual(kept, tt.wantKept) {
+				t.Errorf("RideFeatures() returned kinds %v, kept %v, want %v, %v", kinds, kept, tt.wantKinds, tt.wantKept)
+			}
+		})
+	}
+}
+
+func TestWrite(t *testing.T) {
+	trace := calculator.TraceRide([]calculator.RidePart{
+		{RideID: 1, Coordinate: calculator.Coordinate{Latitude: 49.143352, Longitude: 3.519707}, Timestamp: start},
+		{RideID: 1, Coordinate: calculator.Coordinate{Latitude: 51.365184, Longitude: -2.388245}, Timestamp: start},
+		{RideID: 1, Coordinate: calculator.Coordinate{Latitude: 49.150684, Longitude: 3.532212}, Timestamp: start + hour},
+	}, calculator.DefaultTariff)
+
+	output := bytes.NewBuffer(nil)
+	if err := Write(output, trace); err != nil {
+		t.Fatalf("Write() returned error %v", err)
+	}
+
+	var got struct {
+		Type     string `json:"type"`
+		Features []struct {
+			Geometry struct {
+				Type        string          `json:"type"`
+				Coordinates json.RawMessage `json:"coordinates"`
+			} `json:"geometry"`
+			Properties map[string]interface{} `json:"properties"`
+		} `json:"features"`
+	}
+	if err := json.Unmarshal(output.Bytes(), &got); err != nil {
+		t.Fatalf("Write() wrote invalid JSON %v: %v", output.String(), err)
+	}
+
+	if got.Type != "FeatureCollection" || len(got.Features) != 5 {
+		t.Fatalf("Bad output %v", output.String())
+	}
+
+	// the rejected point is highlighted, and its infinite speed is null
+	rejected := got.Features[1]
+	if rejected.Geometry.Type != "Point" || string(rejected.Geometry.Coordinates) != "[-2.388245,51.365184]" ||
+		rejected.Properties["reason"] != calculator.RejectedOverSpeed || rejected.Properties["speed_kmh"] != nil ||
+		rejected.Properties["marker-color"] != colorRejected {
+		t.Errorf("Bad rejected point %+v", rejected)
+	}
+
+	path := got.Features[3]
+	if path.Geometry.Type != "LineString" || string(path.Geometry.Coordinates) != "[[3.519707,49.143352],[3.532212,49.150684]]" ||
+		path.Properties["kept_points"] != float64(2) || path.Properties["fare_estimate"] != trace.FareEstimate {
+		t.Errorf("Bad path %+v", path)
+	}
+
+	segment := got.Features[4]
+	if segment.Properties["state"] != calculator.StateIdle || segment.Properties["fare"] != trace.Segments[0].Fare ||
+		!reflect.DeepEqual(segment.Properties["bands"], []interface{}{"day"}) {
+		t.Errorf("Bad segment %+v", segment)
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func Test_geojson(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "rides.geojson")
+
+	err := run(context.Background(), []string{"geojson", "-rides", "1,3", "-rides", "2", "testdata/sample_failed.csv", output})
+	if err == nil || !strings.Contains(err.Error(), "rides [3] are not in the source") {
+		t.Errorf("run() returned error %v, want the missing ride", err)
+	}
+
+	var got struct {
+		Features []struct {
+			Properties struct {
+				RideID int64  `json:"id_ride"`
+				Kind   string `json:"kind"`
+			} `json:"properties"`
+		} `json:"features"`
+	}
+	bytes, _ := os.ReadFile(output)
+	if err := json.Unmarshal(bytes, &got); err != nil {
+		t.Fatalf("Bad output `%v`: %v", string(bytes), err)
+	}
+
+	// ride 1 has 4 points, a path and 3 segments, and ride 2 a single point
+	counts := make(map[int64]int)
+	for _, feature := range got.Features {
+		counts[feature.Properties.RideID]++
+	}
+	if counts[1] != 8 || counts[2] != 1 || len(got.Features) != 9 {
+		t.Errorf("Bad features per ride %v", counts)
+	}
+}
+
+func Test_geojson_arguments(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{"missing rides", []string{"geojson", "testdata/sample.csv"}, "missing -rides"},
+		{"missing source", []string{"geojson", "-rides", "1"}, "missing source"},
+		{"target overwriting the source", []string{"geojson", "-rides", "1", "testdata/sample.csv", "testdata/sample.csv"}, "would overwrite the source"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := run(context.Background(), tt.args); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("run() returned error %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -1,64 +1,60 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"fmt"
-	"harry-pap/beat_assignment/calculator"
-	"harry-pap/beat_assignment/concurrency"
-	"harry-pap/beat_assignment/model"
-	"harry-pap/beat_assignment/parser"
 	"os"
+	"os/signal"
 	"sync"
-	"time"
+	"syscall"
 )
 
-const numberOfWorkers = 10
+// commands are the functions running each command, by name, with the arguments following it
+var commands = map[string]func(ctx context.Context, args []string) error{
+	"estimate": runEstimate,
+	"validate": runValidate,
+	"explain":  runExplain,
+	"compare":  runCompare,
+	"geojson":  runGeoJSON,
+}
 
-// Runs the script
-// Is responsible for launching the involved goroutines,
-// opening the involved files and wiring the needed functions
+// Runs the script, exiting with a non-zero status on error, or when interrupted.
+// The first SIGINT or SIGTERM cancels the pipeline, which writes the estimates calculated so far, while a second one
+// terminates the process at once
 func main() {
-	now := time.Now().UTC()
-
-	var wg sync.WaitGroup
-
-	jobs := make(chan []calculator.RidePart, 100)
-	results := make(chan model.RideFareEstimation, numberOfWorkers*20)
-	done := make(chan interface{}, numberOfWorkers)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
 
-	channelCloserInput := concurrency.ChannelCloserInput{Count: numberOfWorkers, Done: done, Results: results, Wg: &wg}
+	err := run(ctx, os.Args[1:])
+	stop()
 
-	launch(func() { concurrency.CloseResultChannelWhenWorkersDone(channelCloserInput) }, &wg)
-
-	for w := 1; w <= 10; w++ {
-		workerInput := concurrency.WorkerInput{Jobs: jobs, Results: results, Done: done, Wg: &wg, Fun: calculator.CalculateFareForRide}
-		launch(func() { concurrency.RunWorker(workerInput) }, &wg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
 	}
-
-	inputFile, inputErr := os.Open(os.Args[1])
-	outputFile, outputErr := os.Create(os.Args[2])
-
-	panicIfNotNil(inputErr)
-	panicIfNotNil(outputErr)
-
-	defer outputFile.Close()
-	defer inputFile.Close()
-
-	launch(func() { concurrency.ResultWriter(outputFile, results, &wg) }, &wg)
-
-	parser.ParseInputCSV(inputFile, jobs)
-
-	close(jobs)
-
-	wg.Wait()
-
-	fmt.Println("Time elapsed: ", time.Since(now))
 }
 
-func panicIfNotNil(err error) {
-	if err != nil {
-		panic(err)
+// run runs the command named by the first argument, or the estimate command when the first argument is not a command,
+// so that the arguments of earlier versions keep working. The usage printed by -h is not an error
+func run(ctx context.Context, args []string) error {
+	command := runEstimate
+	if len(args) > 0 {
+		if named, found := commands[args[0]]; found {
+			command, args = named, args[1:]
+		}
+	}
+
+	if err := command(ctx, args); !errors.Is(err, flag.ErrHelp) {
+		return err
 	}
+	return nil
 }
+
 func launch(fun func(), wg *sync.WaitGroup) {
 	wg.Add(1)
 	go fun()
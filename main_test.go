@@ -1,12 +1,21 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"harry-pap/beat_assignment/compression"
+	"harry-pap/beat_assignment/model"
+	"io"
 	"os"
+	"path/filepath"
 	"reflect"
 	"sort"
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/parquet-go/parquet-go"
 )
 
 // End-to-end tests
@@ -14,6 +23,7 @@ import (
 // Unfortunately, this is OS dependent
 func Test_main(t *testing.T) {
 	type args struct {
+		flags     []string
 		inputFile string
 		want      []string
 	}
@@ -24,23 +34,110 @@ func Test_main(t *testing.T) {
 		{
 			"single artificial CSV",
 			args{
+				nil,
+				"testdata/sample.csv",
+				[]string{"", "1,27.39"},
+			},
+		},
+		{
+			"single artificial CSV with millisecond timestamps",
+			args{
+				[]string{"-timestamp-format", "milliseconds"},
+				"testdata/sample_milliseconds.csv",
+				[]string{"", "1,27.39"},
+			},
+		},
+		{
+			"single artificial CSV with header and extra columns",
+			args{
+				[]string{"-columns", "id_ride=ride,lat=latitude,lng=longitude,timestamp=ts"},
+				"testdata/sample_with_header.csv",
+				[]string{"", "1,27.39"},
+			},
+		},
+		{
+			"single artificial gzip compressed CSV",
+			args{
+				nil,
+				"testdata/sample.csv.gz",
+				[]string{"", "1,27.39"},
+			},
+		},
+		{
+			"single artificial JSON Lines input",
+			args{
+				nil,
+				"testdata/sample.jsonl",
+				[]string{"", "1,27.39"},
+			},
+		},
+		{
+			"single artificial CSV with JSON Lines output",
+			args{
+				[]string{"-output-format", "jsonl"},
 				"testdata/sample.csv",
-				[]string{"", "1,27.62"},
+				[]string{"", `{"id_ride":1,"fare_estimate":27.39}`},
+			},
+		},
+		{
+			"single artificial CSV with a failed ride",
+			args{
+				nil,
+				"testdata/sample_failed.csv",
+				[]string{"", "1,27.39"},
+			},
+		},
+		{
+			"single artificial CSV with a failed ride and a status column",
+			args{
+				[]string{"-status"},
+				"testdata/sample_failed.csv",
+				[]string{"", "1,27.39,ok", "2,,not_enough_segments"},
+			},
+		},
+		{
+			"single artificial CSV with breakdown",
+			args{
+				[]string{"-breakdown"},
+				"testdata/sample.csv",
+				[]string{"",
+					"1,27.39,1.30,0.814,1.06,1.663,1.23,2.0000,23.80,0.00,0,0",
+					"id_ride,fare_estimate,flag,moving_night_km,moving_night_cost,moving_day_km,moving_day_cost,idle_hours,idle_cost,minimum_top_up,discarded_points,reordered_points"},
+			},
+		},
+		{
+			"single artificial CSV with unsorted points",
+			args{
+				[]string{"-breakdown", "-reorder", "-reorder-window", "2"},
+				"testdata/sample_unsorted.csv",
+				[]string{"",
+					"1,27.39,1.30,0.814,1.06,1.663,1.23,2.0000,23.80,0.00,0,1",
+					"id_ride,fare_estimate,flag,moving_night_km,moving_night_cost,moving_day_km,moving_day_cost,idle_hours,idle_cost,minimum_top_up,discarded_points,reordered_points"},
 			},
 		},
 		{
 			"provided CSV",
 			args{
+				nil,
 				"testdata/paths.csv",
 				[]string{"", //last newline
 					"1,11.34", "2,13.10", "3,33.84", "4,3.47", "5,22.78", "6,9.41",
 					"7,30.01", "8,9.21", "9,6.35"},
 			},
 		},
+		{
+			"provided CSV with interleaved rides",
+			args{
+				[]string{"-grouping", "multiplexed", "-max-open-rides", "3", "-spill-dir", os.TempDir()},
+				"testdata/paths_multiplexed.csv",
+				[]string{"",
+					"1,11.34", "2,13.10", "3,33.84", "4,3.47", "5,22.78", "6,9.41",
+					"7,30.01", "8,9.21", "9,6.35"},
+			},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			args := os.Args
 			outputCsv, err := os.CreateTemp("", "output.csv")
 
 			if err != nil {
@@ -48,21 +145,19 @@ func Test_main(t *testing.T) {
 				return
 			}
 
-			args[1] = tt.args.inputFile
-			args[2] = outputCsv.Name()
-
-			finished := make(chan string)
+			finished := make(chan error, 1)
 			go func() {
-				main()
-				finished <- "done"
+				finished <- run(context.Background(), append(tt.args.flags, tt.args.inputFile, outputCsv.Name()))
 			}()
 
 			select {
 
 			case <-time.After(1 * time.Second):
 				t.Errorf("Main failed to complete after 1 second")
-			case <-finished:
-				break
+			case err := <-finished:
+				if err != nil {
+					t.Errorf("run() returned error %v", err)
+				}
 			}
 
 			bytes, _ := os.ReadFile(outputCsv.Name())
@@ -78,3 +173,354 @@ func Test_main(t *testing.T) {
 		})
 	}
 }
+
+// End-to-end test of the ordered output, which is compared without sorting it, through a buffer smaller than the rides
+func Test_main_ordered(t *testing.T) {
+	outputCsv := filepath.Join(t.TempDir(), "output.csv")
+
+	if err := run(context.Background(), []string{"-ordered", "-order-buffer", "2", "testdata/paths.csv", outputCsv}); err != nil {
+		t.Fatalf("run() returned error %v", err)
+	}
+
+	bytes, _ := os.ReadFile(outputCsv)
+
+	want := "1,11.34\n2,13.10\n3,33.84\n4,3.47\n5,22.78\n6,9.41\n7,30.01\n8,9.21\n9,6.35\n"
+	if string(bytes) != want {
+		t.Errorf("End to end ordered output got = %v, want %v", string(bytes), want)
+	}
+}
+
+// End-to-end test of the failure records, written in a temporary file
+func Test_main_failures(t *testing.T) {
+	dir := t.TempDir()
+	outputCsv := filepath.Join(dir, "output.csv")
+	failuresCsv := filepath.Join(dir, "failures.csv")
+
+	if err := run(context.Background(), []string{"-failures", failuresCsv, "testdata/sample_failed.csv", outputCsv}); err != nil {
+		t.Fatalf("run() returned error %v", err)
+	}
+
+	bytes, _ := os.ReadFile(failuresCsv)
+
+	want := "id_ride,error,points\n2,not_enough_segments,1\n"
+	if string(bytes) != want {
+		t.Errorf("End to end failures got = %v, want %v", string(bytes), want)
+	}
+}
+
+func Test_runSummary(t *testing.T) {
+	estimations := []model.RideFareEstimation{
+		{RideID: 1, Breakdown: model.FareBreakdown{ReorderedPoints: 2}},
+		{RideID: 2},
+		{RideID: 3, Breakdown: model.FareBreakdown{ReorderedPoints: 1}},
+		{RideID: 4, Failure: &model.RideFailure{RideID: 4, Kind: "not_enough_segments", Points: 1}},
+	}
+	tests := []struct {
+		name    string
+		reorder bool
+		want    string
+	}{
+		{"failures", false, "The fare of 1 rides could not be calculated, -failures and -status write their errors\n"},
+		{"reordered points", true, "Reordered 3 points in 2 rides, -breakdown writes the count of each ride\n" +
+			"The fare of 1 rides could not be calculated, -failures and -status write their errors\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			summary := &runSummary{}
+			for _, estimation := range estimations {
+				summary.Write(estimation)
+			}
+
+			var got strings.Builder
+			summary.print(&got, tt.reorder)
+			if got.String() != tt.want {
+				t.Errorf("runSummary.print() = %q, want %q", got.String(), tt.want)
+			}
+		})
+	}
+}
+
+// End-to-end test of the overcharge detection, the flagged rides and the driver report are written in temporary files
+func Test_main_flagged(t *testing.T) {
+	outputCsv, err := os.CreateTemp("", "output.csv")
+	if err != nil {
+		t.Fatalf("Failed to create temp file, %s", err)
+	}
+	defer os.Remove(outputCsv.Name())
+
+	flaggedCsv, err := os.CreateTemp("", "flagged.csv")
+	if err != nil {
+		t.Fatalf("Failed to create temp file, %s", err)
+	}
+	defer os.Remove(flaggedCsv.Name())
+
+	driversCsv, err := os.CreateTemp("", "drivers.csv")
+	if err != nil {
+		t.Fatalf("Failed to create temp file, %s", err)
+	}
+	defer os.Remove(driversCsv.Name())
+
+	if err := run(context.Background(), []string{"-charged", "testdata/charged.csv", "-flagged", flaggedCsv.Name(), "-drivers", driversCsv.Name(),
+		"testdata/paths.csv", outputCsv.Name()}); err != nil {
+		t.Fatalf("run() returned error %v", err)
+	}
+
+	bytes, _ := os.ReadFile(flaggedCsv.Name())
+	got := strings.Split(string(bytes), "\n")
+	sort.Strings(got)
+
+	want := []string{"",
+		"2,driver-2,13.10,20.00,6.90,52.7",
+		"4,driver-3,3.47,5.00,1.53,44.1",
+		"id_ride,id_driver,fare_estimate,charged_fare,deviation,deviation_percentage",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("End to end flagged rides got = %v, want %v", got, want)
+	}
+
+	bytes, _ = os.ReadFile(driversCsv.Name())
+
+	wantDrivers := "rank,id_driver,rides,flagged_rides,flagged_ratio,total_overcharge,median_deviation\n" +
+		"1,driver-2,2,1,0.500,6.90,3.45\n" +
+		"2,driver-3,1,1,1.000,1.53,1.53\n" +
+		"3,driver-1,2,0,0.000,0.00,0.33\n"
+	if string(bytes) != wantDrivers {
+		t.Errorf("End to end driver report got = %v, want %v", string(bytes), wantDrivers)
+	}
+}
+
+// End-to-end test of Parquet input and output: the provided CSV is converted to Parquet, and its estimates
+// are written to Parquet
+func Test_main_parquet(t *testing.T) {
+	type ping struct {
+		ID        int64   `parquet:"id_ride"`
+		Latitude  float64 `parquet:"lat"`
+		Longitude float64 `parquet:"lng"`
+		Timestamp int64   `parquet:"timestamp"`
+	}
+	type estimation struct {
+		RideID         int64   `parquet:"id_ride"`
+		CostEstimation float64 `parquet:"fare_estimate"`
+	}
+
+	dir := t.TempDir()
+	inputParquet := filepath.Join(dir, "paths.parquet")
+	outputParquet := filepath.Join(dir, "output.parquet")
+
+	csvData, _ := os.ReadFile("testdata/paths.csv")
+	pings := make([]ping, 0, 2000)
+	for _, line := range strings.Fields(string(csvData)) {
+		var p ping
+		fmt.Sscanf(strings.ReplaceAll(line, ",", " "), "%d %f %f %d", &p.ID, &p.Latitude, &p.Longitude, &p.Timestamp)
+		pings = append(pings, p)
+	}
+
+	inputFile, _ := os.Create(inputParquet)
+	if err := parquet.Write(inputFile, pings); err != nil {
+		t.Fatalf("Failed to write Parquet input, %s", err)
+	}
+	inputFile.Close()
+
+	if err := run(context.Background(), []string{inputParquet, outputParquet}); err != nil {
+		t.Fatalf("run() returned error %v", err)
+	}
+
+	got, err := parquet.ReadFile[estimation](outputParquet)
+	sort.Slice(got, func(i, j int) bool { return got[i].RideID < got[j].RideID })
+
+	want := []estimation{{1, 11.34}, {2, 13.10}, {3, 33.84}, {4, 3.47}, {5, 22.78}, {6, 9.41}, {7, 30.01}, {8, 9.21}, {9, 6.35}}
+	if err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("End to end Parquet estimates got = %v, %v, want %v", got, err, want)
+	}
+}
+
+// End-to-end test of the rejection of malformed lines
+func Test_main_rejects(t *testing.T) {
+	tests := []struct {
+		name       string
+		maxRejects string
+		wantErr    bool
+	}{
+		{"within the default error budget", "", false},
+		{"within the error budget", "1", false},
+		{"exceeding the error budget", "0", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outputCsv, err := os.CreateTemp("", "output.csv")
+			if err != nil {
+				t.Fatalf("Failed to create temp file, %s", err)
+			}
+			defer os.Remove(outputCsv.Name())
+
+			rejectsCsv, err := os.CreateTemp("", "rejects.csv")
+			if err != nil {
+				t.Fatalf("Failed to create temp file, %s", err)
+			}
+			defer os.Remove(rejectsCsv.Name())
+
+			args := []string{"-rejects", rejectsCsv.Name()}
+			if tt.maxRejects != "" {
+				args = append(args, "-max-rejects", tt.maxRejects)
+			}
+
+			err = run(context.Background(), append(args, "testdata/malformed.csv", outputCsv.Name()))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("End to end returned error %v, want error: %v", err, tt.wantErr)
+			}
+
+			bytes, _ := os.ReadFile(rejectsCsv.Name())
+			want := "line_number,raw_row,reason\n3,\"1,49.146490,3.548239,not_a_timestamp\",\"invalid timestamp \"\"not_a_timestamp\"\"\"\n"
+			if string(bytes) != want {
+				t.Errorf("End to end rejects got = %v, want %v", string(bytes), want)
+			}
+		})
+	}
+}
+
+// End-to-end test of writing the estimates into several targets at once, each one in the format of its extension
+func Test_main_alsoOutput(t *testing.T) {
+	dir := t.TempDir()
+	outputCsv := filepath.Join(dir, "output.csv")
+	outputJSONL := filepath.Join(dir, "output.jsonl")
+	outputGzip := filepath.Join(dir, "output.csv.gz")
+
+	if err := run(context.Background(), []string{"-also-output", outputJSONL, "-also-output", outputGzip, "testdata/sample.csv", outputCsv}); err != nil {
+		t.Fatalf("run() returned error %v", err)
+	}
+
+	want := map[string]string{
+		outputCsv:   "1,27.39\n",
+		outputJSONL: `{"id_ride":1,"fare_estimate":27.39}` + "\n",
+		outputGzip:  "1,27.39\n",
+	}
+	for path, wantOutput := range want {
+		file, err := compression.OpenInput(path)
+		if err != nil {
+			t.Fatalf("Failed to open %s, %s", path, err)
+		}
+		bytes, err := io.ReadAll(file)
+		file.Close()
+
+		if err != nil || string(bytes) != wantOutput {
+			t.Errorf("End to end %s got = %q, %v, want %q", filepath.Base(path), bytes, err, wantOutput)
+		}
+	}
+}
+
+// End-to-end test of a cancelled run, which stops reading the input, and still writes a valid, possibly empty, output
+func Test_main_cancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	outputJSONL := filepath.Join(t.TempDir(), "output.jsonl.gz")
+
+	err := run(ctx, []string{"testdata/paths.csv", outputJSONL})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("run() returned error %v, want %v", err, context.Canceled)
+	}
+
+	file, err := compression.OpenInput(outputJSONL)
+	if err != nil {
+		t.Fatalf("Failed to open %s, %s", outputJSONL, err)
+	}
+	defer file.Close()
+
+	if bytes, err := io.ReadAll(file); err != nil || len(bytes) != 0 {
+		t.Errorf("End to end cancelled output got = %q, %v, want an empty output", bytes, err)
+	}
+}
+
+// End-to-end test of the validation of the arguments, which returns an error before reading the source
+func Test_main_arguments(t *testing.T) {
+	dir := t.TempDir()
+	outputCsv := filepath.Join(dir, "output.csv")
+
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{"source and target as flags, with a single worker", []string{"-workers", "1", "-input", "testdata/sample.csv", "-output", outputCsv}, ""},
+		{"target as flag and source as argument", []string{"-output", outputCsv, "testdata/sample.csv"}, ""},
+		{"estimate command", []string{"estimate", "testdata/sample.csv", outputCsv}, ""},
+		{"missing source", nil, "missing source"},
+		{"unknown flag", []string{"-bogus", "testdata/sample.csv", outputCsv}, "flag provided but not defined: -bogus"},
+		{"unknown flag of a command", []string{"validate", "-bogus", "testdata/sample.csv"}, "flag provided but not defined: -bogus"},
+		{"missing target", []string{"testdata/sample.csv"}, "missing target"},
+		{"unexpected argument", []string{"testdata/sample.csv", outputCsv, "extra.csv"}, `unexpected arguments ["extra.csv"]`},
+		{"no workers", []string{"-workers", "0", "testdata/sample.csv", outputCsv}, "-workers must be at least 1, got 0"},
+		{"negative duration", []string{"-inactivity-gap", "-1m", "testdata/sample.csv", outputCsv}, "-inactivity-gap must be at least 0, got -1m0s"},
+		{"negative reorder window of a command", []string{"geojson", "-reorder-window", "-1", "-rides", "1", "testdata/sample.csv"}, "-reorder-window must be at least 0, got -1"},
+		{"missing source file", []string{"testdata/missing.csv", outputCsv}, "cannot read source"},
+		{"target overwriting the source", []string{"testdata/sample.csv", "testdata/sample.csv"}, "would overwrite the source"},
+		{"charged fares without flagged rides", []string{"-charged", "testdata/charged.csv", "testdata/sample.csv", outputCsv}, "missing -flagged"},
+		{"flagged rides overwriting the source", []string{"-charged", "testdata/charged.csv", "-flagged", "testdata/sample.csv", "testdata/sample.csv", outputCsv}, "-flagged testdata/sample.csv would overwrite the source"},
+		{"driver report overwriting the target", []string{"-charged", "testdata/charged.csv", "-flagged", filepath.Join(dir, "flagged.csv"), "-drivers", outputCsv, "testdata/sample.csv", outputCsv}, "would overwrite the target"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Remove(outputCsv)
+
+			err := run(context.Background(), tt.args)
+
+			if tt.wantErr == "" {
+				bytes, _ := os.ReadFile(outputCsv)
+				if err != nil || string(bytes) != "1,27.39\n" {
+					t.Errorf("run() returned %v, and wrote %q, want no error, and the estimate", err, bytes)
+				}
+				return
+			}
+
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("run() returned error %v, want %q", err, tt.wantErr)
+			}
+			if _, statErr := os.Stat(outputCsv); statErr == nil {
+				t.Errorf("run() created the target despite invalid arguments")
+			}
+		})
+	}
+}
+
+func Test_main_help(t *testing.T) {
+	for _, args := range [][]string{{"-h"}, {"explain", "-h"}} {
+		if err := run(context.Background(), args); err != nil {
+			t.Errorf("run(%q) returned error %v, want none", args, err)
+		}
+	}
+}
+
+func Test_main_setupErrors(t *testing.T) {
+	dir := t.TempDir()
+	outputCsv := filepath.Join(dir, "output.csv")
+	badTariff := filepath.Join(dir, "tariff.yaml")
+	os.WriteFile(badTariff, []byte("flag_value: -1\n"), 0o644)
+	badCharged := filepath.Join(dir, "charged.csv")
+	os.WriteFile(badCharged, []byte("1,eleven,driver-1\n"), 0o644)
+	missingDir := filepath.Join(dir, "missing", "output.csv")
+	compressedParquet := filepath.Join(dir, "rides.parquet.gz")
+	gzipped, _ := os.ReadFile("testdata/sample.csv.gz")
+	os.WriteFile(compressedParquet, gzipped, 0o644)
+
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{"invalid tariff", []string{"-tariff", badTariff, "testdata/sample.csv", outputCsv}, "cannot load tariff"},
+		{"malformed charged fares", []string{"-charged", badCharged, "-flagged", filepath.Join(dir, "flagged.csv"), "testdata/sample.csv", outputCsv}, "cannot read charged fares"},
+		{"target that cannot be created", []string{"testdata/sample.csv", missingDir}, "cannot create output"},
+		{"compressed parquet source", []string{compressedParquet, outputCsv}, "is compressed, it must be decompressed first"},
+		{"also-output that cannot be created", []string{"-also-output", missingDir, "testdata/sample.csv", outputCsv}, "cannot create output"},
+		{"rejects that cannot be created", []string{"-rejects", missingDir, "testdata/sample.csv", outputCsv}, "cannot create rejects"},
+		{"explain with an invalid tariff", []string{"explain", "-tariff", badTariff, "1", "testdata/sample.csv"}, "cannot load tariff"},
+		{"geojson with an invalid tariff", []string{"geojson", "-tariff", badTariff, "-rides", "1", "testdata/sample.csv"}, "cannot load tariff"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := run(context.Background(), tt.args); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("run() returned error %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
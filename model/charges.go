@@ -0,0 +1,61 @@
+package model
+
+import "strconv"
+
+// ChargedFare contains the fare actually charged for a ride, and the driver who charged it
+type ChargedFare struct {
+	RideID   int64
+	DriverID string
+	Charged  float64
+}
+
+// FlaggedRide contains a ride whose charged fare deviates from its estimation by more than the tolerance
+type FlaggedRide struct {
+	RideID              int64
+	DriverID            string
+	CostEstimation      float64
+	Charged             float64
+	Deviation           float64
+	DeviationPercentage float64
+}
+
+// FlaggedRideHeader contains the column names of FlaggedRide.ToStringSlice
+var FlaggedRideHeader = []string{"id_ride", "id_driver", "fare_estimate", "charged_fare", "deviation", "deviation_percentage"}
+
+// ToStringSlice converts a FlaggedRide, into a []string, representing its fields
+func (flaggedRide FlaggedRide) ToStringSlice() []string {
+	return []string{
+		strconv.FormatInt(flaggedRide.RideID, 10),
+		flaggedRide.DriverID,
+		formatCost(flaggedRide.CostEstimation),
+		formatCost(flaggedRide.Charged),
+		formatCost(flaggedRide.Deviation),
+		strconv.FormatFloat(flaggedRide.DeviationPercentage, 'f', 1, 64),
+	}
+}
+
+// DriverSummary contains the overcharge statistics of the rides of a driver
+type DriverSummary struct {
+	DriverID        string
+	Rides           int
+	FlaggedRides    int
+	FlaggedRatio    float64
+	TotalOvercharge float64
+	MedianDeviation float64
+}
+
+// DriverSummaryHeader contains the column names of DriverSummary.ToStringSlice
+var DriverSummaryHeader = []string{"rank", "id_driver", "rides", "flagged_rides", "flagged_ratio", "total_overcharge", "median_deviation"}
+
+// ToStringSlice converts a DriverSummary with its rank, into a []string, representing its fields
+func (driverSummary DriverSummary) ToStringSlice(rank int) []string {
+	return []string{
+		strconv.Itoa(rank),
+		driverSummary.DriverID,
+		strconv.Itoa(driverSummary.Rides),
+		strconv.Itoa(driverSummary.FlaggedRides),
+		strconv.FormatFloat(driverSummary.FlaggedRatio, 'f', 3, 64),
+		formatCost(driverSummary.TotalOvercharge),
+		formatCost(driverSummary.MedianDeviation),
+	}
+}
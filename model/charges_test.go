@@ -0,0 +1,24 @@
+package model
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFlaggedRide_toStringSlice(t *testing.T) {
+	flaggedRide := FlaggedRide{RideID: 2, DriverID: "driver-2", CostEstimation: 13.1, Charged: 20, Deviation: 6.9, DeviationPercentage: 52.67175}
+	want := []string{"2", "driver-2", "13.10", "20.00", "6.90", "52.7"}
+
+	if got := flaggedRide.ToStringSlice(); !reflect.DeepEqual(got, want) {
+		t.Errorf("FlaggedRide.ToStringSlice() = %v, want %v", got, want)
+	}
+}
+
+func TestDriverSummary_toStringSlice(t *testing.T) {
+	driverSummary := DriverSummary{DriverID: "driver-2", Rides: 3, FlaggedRides: 1, FlaggedRatio: 1.0 / 3, TotalOvercharge: 6.9, MedianDeviation: 0.333}
+	want := []string{"1", "driver-2", "3", "1", "0.333", "6.90", "0.33"}
+
+	if got := driverSummary.ToStringSlice(1); !reflect.DeepEqual(got, want) {
+		t.Errorf("DriverSummary.ToStringSlice() = %v, want %v", got, want)
+	}
+}
@@ -1,13 +1,70 @@
 package model
 
-import "strconv"
+import (
+	"encoding/json"
+	"strconv"
+)
 
-// RideFareEstimation contains the fare estimation of a ride, including the ride id, and the cost estimation
+// RideFareEstimation contains the fare estimation of a ride, including the ride id, the cost estimation and its breakdown
+// When the fare of the ride could not be calculated, Failure is set, and the cost estimation and breakdown are empty
 type RideFareEstimation struct {
 	RideID         int64
 	CostEstimation float64
+	Breakdown      FareBreakdown
+	Failure        *RideFailure
 }
 
+// RideFailure contains the ride id, the kind of error and the number of points of a ride whose fare could not be
+// calculated
+type RideFailure struct {
+	RideID int64
+	Kind   string
+	Points int
+}
+
+// RideFailureHeader contains the column names of RideFailure.ToStringSlice
+var RideFailureHeader = []string{"id_ride", "error", "points"}
+
+// ToStringSlice converts a RideFailure, into a []string, representing its fields
+func (rideFailure RideFailure) ToStringSlice() []string {
+	return []string{strconv.FormatInt(rideFailure.RideID, 10), rideFailure.Kind, strconv.Itoa(rideFailure.Points)}
+}
+
+// StatusOK is the status of a ride whose fare was calculated
+const StatusOK = "ok"
+
+// Status returns StatusOK, or the kind of error of a failed ride
+func (rideFareEstimation RideFareEstimation) Status() string {
+	if rideFareEstimation.Failure != nil {
+		return rideFareEstimation.Failure.Kind
+	}
+	return StatusOK
+}
+
+// FareBreakdown contains the amounts that add up to the cost estimation of a ride:
+// the flag amount, the km driven and cost of each tariff band, the idle hours and cost,
+// the amount added to reach the minimum fare, the number of points discarded as invalid, and the number of points
+// that were moved when sorting the ride by timestamp
+type FareBreakdown struct {
+	Flag            float64
+	Bands           []BandCharge
+	IdleHours       float64
+	IdleCost        float64
+	MinimumTopUp    float64
+	DiscardedPoints int
+	ReorderedPoints int
+}
+
+// BandCharge contains the km driven while a tariff band applied, and their cost
+type BandCharge struct {
+	Name       string
+	MovingKm   float64
+	MovingCost float64
+}
+
+// EstimationHeader contains the column names of ToStringSlice
+var EstimationHeader = []string{"id_ride", "fare_estimate"}
+
 // ToStringSlice converts a RideFareEstimation, into a []string, representing its fields
 func (rideFareEstimation RideFareEstimation) ToStringSlice() []string {
 	return []string{
@@ -15,3 +72,111 @@ func (rideFareEstimation RideFareEstimation) ToStringSlice() []string {
 		strconv.FormatFloat(rideFareEstimation.CostEstimation, 'f', 2, 64),
 	}
 }
+
+// ToBreakdownStringSlice converts a RideFareEstimation, into a []string, representing its fields followed by
+// the ones of its breakdown, in the order of BreakdownHeader
+func (rideFareEstimation RideFareEstimation) ToBreakdownStringSlice() []string {
+	breakdown := rideFareEstimation.Breakdown
+
+	result := append(rideFareEstimation.ToStringSlice(), formatCost(breakdown.Flag))
+	for _, band := range breakdown.Bands {
+		result = append(result, strconv.FormatFloat(band.MovingKm, 'f', 3, 64), formatCost(band.MovingCost))
+	}
+
+	return append(result,
+		strconv.FormatFloat(breakdown.IdleHours, 'f', 4, 64),
+		formatCost(breakdown.IdleCost),
+		formatCost(breakdown.MinimumTopUp),
+		strconv.Itoa(breakdown.DiscardedPoints),
+		strconv.Itoa(breakdown.ReorderedPoints),
+	)
+}
+
+// BreakdownHeader returns the column names of ToBreakdownStringSlice, for the given tariff band names
+func BreakdownHeader(bandNames []string) []string {
+	result := []string{"id_ride", "fare_estimate", "flag"}
+	for _, name := range bandNames {
+		result = append(result, "moving_"+name+"_km", "moving_"+name+"_cost")
+	}
+
+	return append(result, "idle_hours", "idle_cost", "minimum_top_up", "discarded_points", "reordered_points")
+}
+
+// ToJSON converts a RideFareEstimation into a JSON object, with the fields of ToStringSlice as numbers
+func (rideFareEstimation RideFareEstimation) ToJSON() ([]byte, error) {
+	return json.Marshal(rideFareEstimation.toJSON(false, false))
+}
+
+// ToBreakdownJSON works like ToJSON, but adds a breakdown object, with the fields of ToBreakdownStringSlice
+// and the bands in an array
+func (rideFareEstimation RideFareEstimation) ToBreakdownJSON() ([]byte, error) {
+	return json.Marshal(rideFareEstimation.toJSON(true, false))
+}
+
+// ToStatusJSON works like ToJSON, or ToBreakdownJSON with breakdown, but adds the status of the ride, and for a failed
+// ride only has its id and status
+func (rideFareEstimation RideFareEstimation) ToStatusJSON(breakdown bool) ([]byte, error) {
+	return json.Marshal(rideFareEstimation.toJSON(breakdown, true))
+}
+
+func (rideFareEstimation RideFareEstimation) toJSON(withBreakdown bool, withStatus bool) rideFareEstimationJSON {
+	result := rideFareEstimationJSON{RideID: rideFareEstimation.RideID}
+	if withStatus {
+		result.Status = rideFareEstimation.Status()
+	}
+	if rideFareEstimation.Failure != nil {
+		return result
+	}
+
+	result.CostEstimation = json.Number(formatCost(rideFareEstimation.CostEstimation))
+	if !withBreakdown {
+		return result
+	}
+
+	breakdown := rideFareEstimation.Breakdown
+	result.Breakdown = &fareBreakdownJSON{
+		Flag:            json.Number(formatCost(breakdown.Flag)),
+		Bands:           make([]bandChargeJSON, 0, len(breakdown.Bands)),
+		IdleHours:       json.Number(strconv.FormatFloat(breakdown.IdleHours, 'f', 4, 64)),
+		IdleCost:        json.Number(formatCost(breakdown.IdleCost)),
+		MinimumTopUp:    json.Number(formatCost(breakdown.MinimumTopUp)),
+		DiscardedPoints: breakdown.DiscardedPoints,
+		ReorderedPoints: breakdown.ReorderedPoints,
+	}
+	for _, band := range breakdown.Bands {
+		result.Breakdown.Bands = append(result.Breakdown.Bands, bandChargeJSON{
+			Name:       band.Name,
+			MovingKm:   json.Number(strconv.FormatFloat(band.MovingKm, 'f', 3, 64)),
+			MovingCost: json.Number(formatCost(band.MovingCost)),
+		})
+	}
+
+	return result
+}
+
+type rideFareEstimationJSON struct {
+	RideID         int64              `json:"id_ride"`
+	CostEstimation json.Number        `json:"fare_estimate,omitempty"`
+	Breakdown      *fareBreakdownJSON `json:"breakdown,omitempty"`
+	Status         string             `json:"status,omitempty"`
+}
+
+type fareBreakdownJSON struct {
+	Flag            json.Number      `json:"flag"`
+	Bands           []bandChargeJSON `json:"bands"`
+	IdleHours       json.Number      `json:"idle_hours"`
+	IdleCost        json.Number      `json:"idle_cost"`
+	MinimumTopUp    json.Number      `json:"minimum_top_up"`
+	DiscardedPoints int              `json:"discarded_points"`
+	ReorderedPoints int              `json:"reordered_points"`
+}
+
+type bandChargeJSON struct {
+	Name       string      `json:"name"`
+	MovingKm   json.Number `json:"moving_km"`
+	MovingCost json.Number `json:"moving_cost"`
+}
+
+func formatCost(cost float64) string {
+	return strconv.FormatFloat(cost, 'f', 2, 64)
+}
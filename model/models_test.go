@@ -33,3 +33,107 @@ func TestRideFareEstimation_toStringSlice(t *testing.T) {
 		})
 	}
 }
+
+func TestRideFareEstimation_toBreakdownStringSlice(t *testing.T) {
+	type args struct {
+		rideFareEstimation RideFareEstimation
+	}
+	tests := []struct {
+		name string
+		args args
+		want []string
+	}{
+		{
+			"breakdown fields follow the estimation",
+			args{RideFareEstimation{RideID: 100, CostEstimation: 41.1456, Breakdown: FareBreakdown{
+				Flag: 1.3,
+				Bands: []BandCharge{
+					{Name: "night", MovingKm: 1.23456, MovingCost: 1.604928},
+					{Name: "day", MovingKm: 10, MovingCost: 7.4},
+				},
+				IdleHours:       2.5,
+				IdleCost:        29.75,
+				DiscardedPoints: 3,
+				ReorderedPoints: 2,
+			}}},
+			[]string{"100", "41.15", "1.30", "1.235", "1.60", "10.000", "7.40", "2.5000", "29.75", "0.00", "3", "2"},
+		},
+		{
+			"minimum top up",
+			args{RideFareEstimation{RideID: 100, CostEstimation: 3.47, Breakdown: FareBreakdown{Flag: 1.3, MinimumTopUp: 2.17}}},
+			[]string{"100", "3.47", "1.30", "0.0000", "0.00", "2.17", "0", "0"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.args.rideFareEstimation.ToBreakdownStringSlice(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("RideFareEstimation.ToBreakdownStringSlice() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRideFareEstimation_toJSON(t *testing.T) {
+	estimation := RideFareEstimation{RideID: 100, CostEstimation: 41.1456, Breakdown: FareBreakdown{
+		Flag:            1.3,
+		Bands:           []BandCharge{{Name: "day", MovingKm: 1.23456, MovingCost: 0.9135744}},
+		IdleHours:       2.5,
+		IdleCost:        29.75,
+		MinimumTopUp:    0,
+		DiscardedPoints: 3,
+		ReorderedPoints: 1,
+	}}
+	tests := []struct {
+		name   string
+		toJSON func(RideFareEstimation) ([]byte, error)
+		want   string
+	}{
+		{"estimation", RideFareEstimation.ToJSON, `{"id_ride":100,"fare_estimate":41.15}`},
+		{
+			"estimation with breakdown",
+			RideFareEstimation.ToBreakdownJSON,
+			`{"id_ride":100,"fare_estimate":41.15,"breakdown":{"flag":1.30,"bands":[{"name":"day","moving_km":1.235,"moving_cost":0.91}],` +
+				`"idle_hours":2.5000,"idle_cost":29.75,"minimum_top_up":0.00,"discarded_points":3,"reordered_points":1}}`,
+		},
+		{
+			"estimation with status",
+			func(estimation RideFareEstimation) ([]byte, error) { return estimation.ToStatusJSON(false) },
+			`{"id_ride":100,"fare_estimate":41.15,"status":"ok"}`,
+		},
+		{
+			"failed ride with status and breakdown",
+			func(estimation RideFareEstimation) ([]byte, error) {
+				return RideFareEstimation{RideID: 100, Failure: &RideFailure{RideID: 100, Kind: "not_enough_segments", Points: 1}}.ToStatusJSON(true)
+			},
+			`{"id_ride":100,"status":"not_enough_segments"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, err := tt.toJSON(estimation); err != nil || string(got) != tt.want {
+				t.Errorf("RideFareEstimation JSON = %s, %v, want %s", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestBreakdownHeader(t *testing.T) {
+	want := []string{"id_ride", "fare_estimate", "flag", "moving_night_km", "moving_night_cost", "moving_day_km", "moving_day_cost",
+		"idle_hours", "idle_cost", "minimum_top_up", "discarded_points", "reordered_points"}
+
+	if got := BreakdownHeader([]string{"night", "day"}); !reflect.DeepEqual(got, want) {
+		t.Errorf("BreakdownHeader() = %v, want %v", got, want)
+	}
+}
+
+func TestRideFailure_toStringSlice(t *testing.T) {
+	failure := RideFailure{RideID: 7, Kind: "not_enough_segments", Points: 1}
+
+	want := []string{"7", "not_enough_segments", "1"}
+	if got := failure.ToStringSlice(); !reflect.DeepEqual(got, want) {
+		t.Errorf("RideFailure.toStringSlice() = %v, want %v", got, want)
+	}
+	if got := (RideFareEstimation{RideID: 7, Failure: &failure}).Status(); got != failure.Kind {
+		t.Errorf("RideFareEstimation.Status() = %v, want %v", got, failure.Kind)
+	}
+}
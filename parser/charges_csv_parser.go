@@ -0,0 +1,50 @@
+package parser
+
+import (
+	"encoding/csv"
+	"fmt"
+	"harry-pap/beat_assignment/model"
+	"io"
+	"strconv"
+)
+
+// ParseChargedFaresCSV reads a CSV of (id_ride, charged_fare, id_driver) lines, into a map of ChargedFares by ride id
+// A first line whose ride id is not a number is skipped as a header
+// An error is returned for the first malformed line, or for a ride id found twice
+func ParseChargedFaresCSV(file io.Reader) (map[int64]model.ChargedFare, error) {
+	result := make(map[int64]model.ChargedFare)
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = 3
+
+	for lineNumber := 1; ; lineNumber++ {
+		line, err := reader.Read()
+
+		if err == io.EOF {
+			return result, nil
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		id, err := strconv.ParseInt(line[0], 10, 64)
+		if err != nil {
+			if lineNumber == 1 {
+				continue
+			}
+			return nil, fmt.Errorf("line %d: invalid ride id %q", lineNumber, line[0])
+		}
+
+		if _, found := result[id]; found {
+			return nil, fmt.Errorf("line %d: duplicate ride id %d", lineNumber, id)
+		}
+
+		charged, err := strconv.ParseFloat(line[1], 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid charged fare %q", lineNumber, line[1])
+		}
+
+		result[id] = model.ChargedFare{RideID: id, DriverID: line[2], Charged: charged}
+	}
+}
@@ -0,0 +1,69 @@
+package parser
+
+import (
+	"harry-pap/beat_assignment/model"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseChargedFaresCSV(t *testing.T) {
+	type want struct {
+		res     map[int64]model.ChargedFare
+		wantErr bool
+	}
+	tests := []struct {
+		name    string
+		csvData string
+		want    want
+	}{
+		{
+			"no charges",
+			"",
+			want{map[int64]model.ChargedFare{}, false},
+		},
+		{
+			"2 charges",
+			"1,11.34,driver-1\n2,13.10,driver-2\n",
+			want{map[int64]model.ChargedFare{
+				1: {RideID: 1, DriverID: "driver-1", Charged: 11.34},
+				2: {RideID: 2, DriverID: "driver-2", Charged: 13.10},
+			}, false},
+		},
+		{
+			"header is skipped",
+			"id_ride,charged_fare,id_driver\n1,11.34,driver-1\n",
+			want{map[int64]model.ChargedFare{
+				1: {RideID: 1, DriverID: "driver-1", Charged: 11.34},
+			}, false},
+		},
+		{
+			"invalid ride id returns an error",
+			"1,11.34,driver-1\none,11.34,driver-1\n",
+			want{nil, true},
+		},
+		{
+			"duplicate ride id returns an error",
+			"1,11.34,driver-1\n1,13.10,driver-2\n",
+			want{nil, true},
+		},
+		{
+			"invalid charged fare returns an error",
+			"1,eleven,driver-1\n",
+			want{nil, true},
+		},
+		{
+			"missing driver returns an error",
+			"1,11.34\n",
+			want{nil, true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseChargedFaresCSV(strings.NewReader(tt.csvData))
+			if (err != nil) != tt.want.wantErr || !reflect.DeepEqual(got, tt.want.res) {
+				t.Errorf("ParseChargedFaresCSV() = %v, %v, want %v, error: %v", got, err, tt.want.res, tt.want.wantErr)
+			}
+		})
+	}
+}
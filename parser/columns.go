@@ -0,0 +1,210 @@
+package parser
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// HeaderMode is whether the first line of the input is a header
+type HeaderMode int
+
+const (
+	// HeaderAuto treats the first line as a header when a column is mapped by name, or when none of its fields is a number
+	HeaderAuto HeaderMode = iota
+	// HeaderPresent always treats the first line as a header
+	HeaderPresent
+	// HeaderAbsent never treats the first line as a header
+	HeaderAbsent
+)
+
+var headerModeNames = enumNames{"auto", "yes", "no"}
+
+// String returns the name of the mode
+func (mode HeaderMode) String() string {
+	return headerModeNames.name(int(mode))
+}
+
+// Set sets the mode by its name, so that HeaderMode can be used as a flag.Value
+func (mode *HeaderMode) Set(name string) error {
+	value, err := headerModeNames.parse("header mode", name)
+	if err != nil {
+		return err
+	}
+
+	*mode = HeaderMode(value)
+	return nil
+}
+
+// ColumnMapping contains the columns of the input holding the fields of a RidePart, each one either a header name,
+// or a 0-based index. Empty columns default to the order id_ride, lat, lng, timestamp.
+// End is the optional column of the end-of-ride marker, which is true(e.g. 1, true or end) on the last line of a ride
+type ColumnMapping struct {
+	ID        string
+	Latitude  string
+	Longitude string
+	Timestamp string
+	End       string
+}
+
+// DefaultColumnMapping is the column order of the input when no mapping is given
+var DefaultColumnMapping = ColumnMapping{ID: "0", Latitude: "1", Longitude: "2", Timestamp: "3"}
+
+// String formats the mapping as the comma separated field=column pairs accepted by Set
+func (mapping ColumnMapping) String() string {
+	mapping = mapping.withDefaults()
+
+	result := fmt.Sprintf("id_ride=%s,lat=%s,lng=%s,timestamp=%s", mapping.ID, mapping.Latitude, mapping.Longitude, mapping.Timestamp)
+	if mapping.End != "" {
+		result += ",end=" + mapping.End
+	}
+	return result
+}
+
+// Set parses comma separated field=column pairs(e.g. id_ride=ride,lat=3,lng=4), where the field is one of id_ride, lat,
+// lng, timestamp or end, so that ColumnMapping can be used as a flag.Value
+func (mapping *ColumnMapping) Set(text string) error {
+	for _, pair := range strings.Split(text, ",") {
+		field, column, found := strings.Cut(pair, "=")
+		field, column = strings.TrimSpace(field), strings.TrimSpace(column)
+
+		if !found || column == "" {
+			return fmt.Errorf("invalid column mapping %q, expected field=column", pair)
+		}
+
+		switch field {
+		case "id_ride":
+			mapping.ID = column
+		case "lat":
+			mapping.Latitude = column
+		case "lng":
+			mapping.Longitude = column
+		case "timestamp":
+			mapping.Timestamp = column
+		case "end":
+			mapping.End = column
+		default:
+			return fmt.Errorf("unknown field %q, expected one of id_ride, lat, lng, timestamp, end", field)
+		}
+	}
+	return nil
+}
+
+func (mapping ColumnMapping) withDefaults() ColumnMapping {
+	if mapping.ID == "" {
+		mapping.ID = DefaultColumnMapping.ID
+	}
+	if mapping.Latitude == "" {
+		mapping.Latitude = DefaultColumnMapping.Latitude
+	}
+	if mapping.Longitude == "" {
+		mapping.Longitude = DefaultColumnMapping.Longitude
+	}
+	if mapping.Timestamp == "" {
+		mapping.Timestamp = DefaultColumnMapping.Timestamp
+	}
+	return mapping
+}
+
+// columns returns the mapped columns, including the end-of-ride marker one when it is mapped
+func (mapping ColumnMapping) columns() []string {
+	result := []string{mapping.ID, mapping.Latitude, mapping.Longitude, mapping.Timestamp}
+	if mapping.End != "" {
+		result = append(result, mapping.End)
+	}
+	return result
+}
+
+// defaultFieldNames are the names of the fields of a RidePart, and of the end-of-ride marker, in the formats
+// whose fields are always named, like JSON Lines and Parquet
+var defaultFieldNames = []string{"id_ride", "lat", "lng", "timestamp", "end"}
+
+// fieldNames returns the names of the mapped fields, using the default name of the fields mapped by index
+func (mapping ColumnMapping) fieldNames() []string {
+	result := make([]string, 0, len(defaultFieldNames))
+
+	for i, column := range mapping.withDefaults().columns() {
+		if _, err := strconv.Atoi(column); err == nil {
+			column = defaultFieldNames[i]
+		}
+		result = append(result, column)
+	}
+	return result
+}
+
+func (mapping ColumnMapping) hasNames() bool {
+	for _, column := range mapping.withDefaults().columns() {
+		if _, err := strconv.Atoi(column); err != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// columnIndexes contains the resolved indexes of the fields of a RidePart, the one of the end-of-ride marker,
+// which is -1 when it is not mapped, and the number of fields of every line
+type columnIndexes struct {
+	id        int
+	latitude  int
+	longitude int
+	timestamp int
+	end       int
+	fields    int
+}
+
+var defaultColumnIndexes = columnIndexes{id: 0, latitude: 1, longitude: 2, timestamp: 3, end: -1, fields: 4}
+
+// resolve returns the indexes of the mapped columns, looking names up in the header, which can be nil.
+// Every line is expected to have as many fields as the given first line
+func (mapping ColumnMapping) resolve(header []string, firstLine []string) (columnIndexes, error) {
+	indexes := make([]int, 0, 5)
+
+	for _, column := range mapping.withDefaults().columns() {
+		index, err := strconv.Atoi(column)
+
+		if err != nil {
+			index = -1
+			for i, name := range header {
+				if strings.EqualFold(strings.TrimSpace(name), column) {
+					index = i
+					break
+				}
+			}
+			if index < 0 {
+				return columnIndexes{}, fmt.Errorf("column %q is not in the header %v", column, header)
+			}
+		}
+
+		if index < 0 || index >= len(firstLine) {
+			return columnIndexes{}, fmt.Errorf("column %q is out of the %d fields of the line", column, len(firstLine))
+		}
+		indexes = append(indexes, index)
+	}
+
+	result := columnIndexes{id: indexes[0], latitude: indexes[1], longitude: indexes[2], timestamp: indexes[3], end: -1, fields: len(firstLine)}
+	if len(indexes) > 4 {
+		result.end = indexes[4]
+	}
+	return result, nil
+}
+
+// isHeader returns whether the first line of the input is a header
+func (mode HeaderMode) isHeader(line []string, mapping ColumnMapping) bool {
+	switch mode {
+	case HeaderPresent:
+		return true
+	case HeaderAbsent:
+		return false
+	}
+
+	if mapping.hasNames() {
+		return true
+	}
+
+	for _, field := range line {
+		if _, err := strconv.ParseFloat(strings.TrimSpace(field), 64); err == nil {
+			return false
+		}
+	}
+	return true
+}
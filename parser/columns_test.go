@@ -0,0 +1,186 @@
+package parser
+
+import (
+	"harry-pap/beat_assignment/calculator"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestColumnMapping_Set(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		want    ColumnMapping
+		wantErr bool
+	}{
+		{"names and indexes", "id_ride=ride, lat=3,lng=longitude", ColumnMapping{ID: "ride", Latitude: "3", Longitude: "longitude"}, false},
+		{"end-of-ride marker", "end=status", ColumnMapping{End: "status"}, false},
+		{"unknown field", "driver=1", ColumnMapping{}, true},
+		{"missing column", "lat=", ColumnMapping{}, true},
+		{"missing separator", "lat", ColumnMapping{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got ColumnMapping
+			if err := got.Set(tt.text); (err != nil) != tt.wantErr || (err == nil && got != tt.want) {
+				t.Errorf("ColumnMapping.Set() = %v, %v, want %v, error: %v", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestColumnMapping_resolve(t *testing.T) {
+	type args struct {
+		mapping   ColumnMapping
+		header    []string
+		firstLine []string
+	}
+	type want struct {
+		res     columnIndexes
+		wantErr bool
+	}
+	tests := []struct {
+		name string
+		args args
+		want want
+	}{
+		{
+			"default mapping",
+			args{ColumnMapping{}, nil, []string{"1", "2", "3", "4"}},
+			want{defaultColumnIndexes, false},
+		},
+		{
+			"extra columns",
+			args{ColumnMapping{}, nil, []string{"1", "2", "3", "4", "5"}},
+			want{columnIndexes{id: 0, latitude: 1, longitude: 2, timestamp: 3, end: -1, fields: 5}, false},
+		},
+		{
+			"names are looked up in the header",
+			args{ColumnMapping{ID: "Ride", Latitude: "lat", Longitude: "lng", Timestamp: "ts"},
+				[]string{"driver_id", "ts", "lng", "lat", "ride"}, []string{"driver_id", "ts", "lng", "lat", "ride"}},
+			want{columnIndexes{id: 4, latitude: 3, longitude: 2, timestamp: 1, end: -1, fields: 5}, false},
+		},
+		{
+			"end-of-ride marker",
+			args{ColumnMapping{End: "status"}, []string{"id", "lat", "lng", "ts", "status"}, []string{"id", "lat", "lng", "ts", "status"}},
+			want{columnIndexes{id: 0, latitude: 1, longitude: 2, timestamp: 3, end: 4, fields: 5}, false},
+		},
+		{
+			"missing name returns an error",
+			args{ColumnMapping{ID: "ride"}, []string{"id", "lat", "lng", "ts"}, []string{"id", "lat", "lng", "ts"}},
+			want{columnIndexes{}, true},
+		},
+		{
+			"index out of the line returns an error",
+			args{ColumnMapping{Timestamp: "7"}, nil, []string{"1", "2", "3", "4"}},
+			want{columnIndexes{}, true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.args.mapping.resolve(tt.args.header, tt.args.firstLine)
+			if (err != nil) != tt.want.wantErr || got != tt.want.res {
+				t.Errorf("ColumnMapping.resolve() = %v, %v, want %v, error: %v", got, err, tt.want.res, tt.want.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseInputCSV_columns(t *testing.T) {
+	want := [][]calculator.RidePart{{
+		{RideID: 1, Coordinate: calculator.Coordinate{Latitude: 37.966660, Longitude: 23.728308}, Timestamp: 1405594957000000000},
+		{RideID: 1, Coordinate: calculator.Coordinate{Latitude: 37.966627, Longitude: 23.728263}, Timestamp: 1405594966000000000},
+	}}
+	type args struct {
+		options Options
+		csvData string
+	}
+	tests := []struct {
+		name    string
+		args    args
+		wantErr bool
+	}{
+		{
+			"detected header",
+			args{Options{}, "id,lat,lng,timestamp\n1,37.966660,23.728308,1405594957\n1,37.966627,23.728263,1405594966\n"},
+			false,
+		},
+		{
+			"columns mapped by name, with extra columns",
+			args{Options{Columns: ColumnMapping{ID: "ride", Latitude: "latitude", Longitude: "longitude", Timestamp: "ts"}},
+				"driver_id,ts,accuracy,longitude,latitude,ride\n" +
+					"7,1405594957,5.0,23.728308,37.966660,1\n" +
+					"7,1405594966,4.5,23.728263,37.966627,1\n"},
+			false,
+		},
+		{
+			"columns mapped by index, without header",
+			args{Options{Columns: ColumnMapping{ID: "1", Latitude: "2", Longitude: "3", Timestamp: "0"}},
+				"1405594957,1,37.966660,23.728308\n1405594966,1,37.966627,23.728263\n"},
+			false,
+		},
+		{
+			"forced header",
+			args{Options{Header: HeaderPresent}, "1,2,3,4\n1,37.966660,23.728308,1405594957\n1,37.966627,23.728263,1405594966\n"},
+			false,
+		},
+		{
+			"columns mapped by name without header returns an error",
+			args{Options{Header: HeaderAbsent, Columns: ColumnMapping{ID: "ride"}}, "1,37.966660,23.728308,1405594957\n"},
+			true,
+		},
+		{
+			"name missing from the header returns an error",
+			args{Options{Columns: ColumnMapping{ID: "ride"}}, "id,lat,lng,timestamp\n1,37.966660,23.728308,1405594957\n"},
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			channel := make(chan []calculator.RidePart, 10)
+
+			err := ParseInputCSV(strings.NewReader(tt.args.csvData), channel, tt.args.options)
+			close(channel)
+
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseInputCSV() returned error %v, want error: %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			got := make([][]calculator.RidePart, 0, 10)
+			for rides := range channel {
+				got = append(got, rides)
+			}
+
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("ParseInputCSV() pushed to channel %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestHeaderMode_isHeader(t *testing.T) {
+	tests := []struct {
+		name    string
+		mode    HeaderMode
+		line    []string
+		mapping ColumnMapping
+		want    bool
+	}{
+		{"auto with names", HeaderAuto, []string{"id", "lat", "lng", "timestamp"}, ColumnMapping{}, true},
+		{"auto with numbers", HeaderAuto, []string{"1", "37.9", "23.7", "1405594957"}, ColumnMapping{}, false},
+		{"auto with columns mapped by name", HeaderAuto, []string{"1", "37.9", "23.7", "1405594957"}, ColumnMapping{ID: "id"}, true},
+		{"present", HeaderPresent, []string{"1", "37.9", "23.7", "1405594957"}, ColumnMapping{}, true},
+		{"absent", HeaderAbsent, []string{"id", "lat", "lng", "timestamp"}, ColumnMapping{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.mode.isHeader(tt.line, tt.mapping); got != tt.want {
+				t.Errorf("HeaderMode.isHeader() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
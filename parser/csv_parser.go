@@ -1,73 +1,278 @@
 package parser
 
 import (
+	"bytes"
 	"encoding/csv"
+	"errors"
 	"fmt"
 	"harry-pap/beat_assignment/calculator"
 	"io"
+	"math"
 	"strconv"
+	"strings"
+	"time"
 )
 
+// ErrTooManyRejects is returned when more lines than Options.MaxRejects are rejected
+var ErrTooManyRejects = errors.New("too_many_rejected_lines")
+
+var errColumnMapping = errors.New("invalid_column_mapping")
+
+// RejectsHeader contains the column names of the rejects CSV
+var RejectsHeader = []string{"line_number", "raw_row", "reason"}
+
+// Options contains the options of ParseInputCSV
+type Options struct {
+	// Rejects is written with a header, followed by a line_number, raw_row, reason line per rejected line. Can be nil
+	Rejects io.Writer
+	// OnReject is called with the line number, raw row and reason of each rejected line, as it is rejected. Can be nil
+	OnReject func(lineNumber int, raw string, reason error)
+	// MaxRejects is the number of lines that can be rejected before parsing is aborted, a negative value means no limit
+	MaxRejects int
+	// TimestampFormat is the format of the timestamp column, Unix seconds by default
+	TimestampFormat TimestampFormat
+	// Header is whether the first line is a header, detected by default
+	Header HeaderMode
+	// Columns maps the columns of the input to the fields of a RidePart, id_ride, lat, lng, timestamp by default
+	Columns ColumnMapping
+	// Grouping is how the lines are grouped into rides, GroupContiguous by default
+	Grouping Grouping
+	// InactivityGap completes a multiplexed ride, when a line is timestamped more than it after the last line of
+	// the ride. Zero means that rides are only completed by their end-of-ride marker, or at the end of the input
+	InactivityGap time.Duration
+	// MaxOpenRides is the number of multiplexed rides buffered in memory, before the parts of the least recently
+	// updated one are spilled to disk. Zero means no limit
+	MaxOpenRides int
+	// SpillDir is the directory of the spill file, the default directory for temporary files when empty
+	SpillDir string
+}
+
 // ParseInputCSV reads a given *afero.File CSV file, parses each line into a RidePart,
 // batches the ride parts using the rideId, and pushes them to given channel
-// Every 10,000 rides, a message is printed to standard output
-func ParseInputCSV(file io.Reader, channel chan []calculator.RidePart) {
-	var lastID int64
-	var rides = make([]calculator.RidePart, 0, 512)
-	var rideCounter int64
+// By default the lines of each ride are expected to be contiguous. With GroupMultiplexed the lines of many rides can
+// be interleaved, and each ride is pushed on its end-of-ride marker, after Options.InactivityGap, or at the end
+// Malformed lines are skipped, and written to Options.Rejects. If more than Options.MaxRejects lines are rejected,
+// parsing stops and ErrTooManyRejects is returned
+// The first line can be a header, and the columns of the fields are looked up with Options.Columns. Every line is
+// expected to have as many fields as the first one, so that extra columns are allowed but ignored
+// Every 10,000 rides, a message is printed to standard error
+func ParseInputCSV(file io.Reader, channel chan []calculator.RidePart, options Options) error {
+	return pushAndClose(NewCSVSource(file, options), channel)
+}
 
-	reader := csv.NewReader(file)
-	initialized := false
+// csvEntryReader reads the entries of a CSV input, skipping its header
+type csvEntryReader struct {
+	reader *csv.Reader
+	// raw records the input of the reader, for the raw rows of the rejected lines
+	raw   *rawRecorder
+	lines lineParser
+}
 
+func (entries *csvEntryReader) read() (inputEntry, error) {
 	for {
-		line, err := reader.Read()
+		line, err := entries.reader.Read()
+		raw := entries.raw.next(entries.reader.InputOffset())
 
 		if err == io.EOF {
-			break
+			return inputEntry{}, err
 		}
 
 		if err != nil {
-			panic(err)
+			var parseError *csv.ParseError
+			if !errors.As(err, &parseError) {
+				return inputEntry{}, err
+			}
+			return inputEntry{}, &rejectedLine{lineNumber: parseError.StartLine, raw: string(raw), reason: err}
 		}
 
-		entry := parseEntry(line)
+		lineNumber, _ := entries.reader.FieldPos(0)
+		isEntry, part, end, err := entries.lines.parse(line)
 
-		if initialized == false || entry.RideID == lastID {
-			if initialized == false {
-				lastID = entry.RideID
-				initialized = true
-			}
-			rides = append(rides, entry)
-		} else {
-			rideCounter++
+		if errors.Is(err, errColumnMapping) {
+			return inputEntry{}, err
+		}
+		if err != nil {
+			return inputEntry{}, &rejectedLine{lineNumber: lineNumber, raw: string(raw), reason: err}
+		}
+		if isEntry {
+			return inputEntry{part: part, end: end}, nil
+		}
+	}
+}
+
+// rawRecorder records the bytes read from a reader, so that the raw row of each CSV record can be sliced out of them
+// by its input offset, including the quotes and the lines of a row that cannot be parsed
+type rawRecorder struct {
+	reader   io.Reader
+	recorded []byte
+	// offset is the input offset of the first recorded byte
+	offset int64
+}
 
-			if rideCounter%10000 == 0 {
-				fmt.Printf("Processed %d rides\n", rideCounter)
+func (recorder *rawRecorder) Read(p []byte) (int, error) {
+	n, err := recorder.reader.Read(p)
+	recorder.recorded = append(recorder.recorded, p[:n]...)
+	return n, err
+}
+
+// next returns the recorded bytes up to the input offset end, without their line ending, and forgets them. The result
+// is only valid until the next Read
+func (recorder *rawRecorder) next(end int64) []byte {
+	length := int(end - recorder.offset)
+	raw := recorder.recorded[:length]
+
+	recorder.recorded = recorder.recorded[length:]
+	recorder.offset = end
+
+	return bytes.TrimRight(raw, "\r\n")
+}
+
+// lineParser detects the header, and resolves the column mapping, on the first line
+type lineParser struct {
+	options      Options
+	firstChecked bool
+	header       []string
+	columns      *columnIndexes
+}
+
+// parse returns whether the line is an entry, the RidePart it contains, and whether it is the last one of its ride
+func (lines *lineParser) parse(line []string) (bool, calculator.RidePart, bool, error) {
+	if !lines.firstChecked {
+		lines.firstChecked = true
+
+		if lines.options.Header.isHeader(line, lines.options.Columns) {
+			lines.header = append([]string(nil), line...)
+
+			columns, err := lines.options.Columns.resolve(lines.header, lines.header)
+			if err != nil {
+				return false, calculator.RidePart{}, false, fmt.Errorf("%w: %s", errColumnMapping, err)
 			}
+			lines.columns = &columns
 
-			channel <- rides
+			return false, calculator.RidePart{}, false, nil
+		}
 
-			lastID = entry.RideID
+		if lines.options.Columns.hasNames() {
+			return false, calculator.RidePart{}, false, fmt.Errorf("%w: columns are mapped by name, but the input has no header", errColumnMapping)
+		}
+	}
 
-			rides = make([]calculator.RidePart, 0, 512)
-			rides = append(rides, entry)
+	if lines.columns == nil {
+		columns, err := lines.options.Columns.resolve(nil, line)
+		if err != nil {
+			return false, calculator.RidePart{}, false, err
 		}
+		lines.columns = &columns
 	}
 
-	if len(rides) > 0 {
-		channel <- rides
+	entry, err := parseEntry(line, *lines.columns, lines.options.TimestampFormat)
+	if err != nil {
+		return false, entry, false, err
 	}
+
+	end, err := parseEnd(line, *lines.columns)
+
+	return err == nil, entry, end, err
 }
 
-func parseEntry(line []string) calculator.RidePart {
-	id, _ := strconv.ParseInt(line[0], 10, 32)
-	lat, _ := strconv.ParseFloat(line[1], 64)
-	long, _ := strconv.ParseFloat(line[2], 64)
-	timestamp, _ := strconv.ParseInt(line[3], 10, 32)
+func parseEntry(line []string, columns columnIndexes, timestampFormat TimestampFormat) (calculator.RidePart, error) {
+	if len(line) != columns.fields {
+		return calculator.RidePart{}, fmt.Errorf("expected %d fields, got %d", columns.fields, len(line))
+	}
+
+	id, err := strconv.ParseInt(line[columns.id], 10, 64)
+	if err != nil {
+		return calculator.RidePart{}, fmt.Errorf("invalid id_ride %q", line[columns.id])
+	}
+
+	lat, err := strconv.ParseFloat(line[columns.latitude], 64)
+	if err != nil || math.IsNaN(lat) || lat < -90 || lat > 90 {
+		return calculator.RidePart{}, fmt.Errorf("invalid lat %q", line[columns.latitude])
+	}
+
+	long, err := strconv.ParseFloat(line[columns.longitude], 64)
+	if err != nil || math.IsNaN(long) || long < -180 || long > 180 {
+		return calculator.RidePart{}, fmt.Errorf("invalid lng %q", line[columns.longitude])
+	}
+
+	timestamp, err := parseTimestamp(line[columns.timestamp], timestampFormat)
+	if err != nil {
+		return calculator.RidePart{}, err
+	}
 
 	return calculator.RidePart{
 		RideID:     id,
 		Coordinate: calculator.Coordinate{Latitude: lat, Longitude: long},
-		Timestamp:  int32(timestamp),
+		Timestamp:  timestamp,
+	}, nil
+}
+
+// parseEnd returns whether the end-of-ride marker of the line is set, which is false when it is not mapped.
+// The marker is set by a true boolean(e.g. 1 or true) or by end, and unset by a false boolean or an empty field
+func parseEnd(line []string, columns columnIndexes) (bool, error) {
+	if columns.end < 0 {
+		return false, nil
+	}
+
+	marker := strings.TrimSpace(line[columns.end])
+	if marker == "" {
+		return false, nil
+	}
+	if strings.EqualFold(marker, "end") {
+		return true, nil
+	}
+
+	end, err := strconv.ParseBool(marker)
+	if err != nil {
+		return false, fmt.Errorf("invalid end %q", line[columns.end])
+	}
+	return end, nil
+}
+
+// rejectWriter counts the rejected lines against the error budget, and writes them to Options.Rejects and
+// Options.OnReject
+type rejectWriter struct {
+	writer     *csv.Writer
+	onReject   func(lineNumber int, raw string, reason error)
+	maxRejects int
+	count      int
+}
+
+func newRejectWriter(options Options) *rejectWriter {
+	result := &rejectWriter{maxRejects: options.MaxRejects, onReject: options.OnReject}
+
+	if options.Rejects != nil {
+		result.writer = csv.NewWriter(options.Rejects)
+		result.writer.Write(RejectsHeader)
 	}
+
+	return result
+}
+
+func (rejects *rejectWriter) reject(line *rejectedLine) error {
+	rejects.count++
+
+	if rejects.writer != nil {
+		if err := rejects.writer.Write([]string{strconv.Itoa(line.lineNumber), line.raw, line.reason.Error()}); err != nil {
+			return err
+		}
+	}
+	if rejects.onReject != nil {
+		rejects.onReject(line.lineNumber, line.raw, line.reason)
+	}
+
+	if rejects.maxRejects >= 0 && rejects.count > rejects.maxRejects {
+		return fmt.Errorf("%w: line %d is the rejected line number %d, the limit is %d: %s",
+			ErrTooManyRejects, line.lineNumber, rejects.count, rejects.maxRejects, line.reason)
+	}
+	return nil
+}
+
+func (rejects *rejectWriter) flush() error {
+	if rejects.writer == nil {
+		return nil
+	}
+
+	rejects.writer.Flush()
+	return rejects.writer.Error()
 }
@@ -1,6 +1,8 @@
 package parser
 
 import (
+	"bytes"
+	"errors"
 	"harry-pap/beat_assignment/calculator"
 	"reflect"
 	"strings"
@@ -37,10 +39,10 @@ func TestParseInputCSV(t *testing.T) {
 1,37.966613,23.728375,1405594984`,
 				[][]calculator.RidePart{
 					{
-						{RideID: 1, Coordinate: calculator.Coordinate{Latitude: 37.966660, Longitude: 23.728308}, Timestamp: 1405594957},
-						{RideID: 1, Coordinate: calculator.Coordinate{Latitude: 37.966627, Longitude: 23.728263}, Timestamp: 1405594966},
-						{RideID: 1, Coordinate: calculator.Coordinate{Latitude: 37.966625, Longitude: 23.728263}, Timestamp: 1405594974},
-						{RideID: 1, Coordinate: calculator.Coordinate{Latitude: 37.966613, Longitude: 23.728375}, Timestamp: 1405594984},
+						{RideID: 1, Coordinate: calculator.Coordinate{Latitude: 37.966660, Longitude: 23.728308}, Timestamp: 1405594957000000000},
+						{RideID: 1, Coordinate: calculator.Coordinate{Latitude: 37.966627, Longitude: 23.728263}, Timestamp: 1405594966000000000},
+						{RideID: 1, Coordinate: calculator.Coordinate{Latitude: 37.966625, Longitude: 23.728263}, Timestamp: 1405594974000000000},
+						{RideID: 1, Coordinate: calculator.Coordinate{Latitude: 37.966613, Longitude: 23.728375}, Timestamp: 1405594984000000000},
 					},
 				},
 			},
@@ -62,19 +64,19 @@ func TestParseInputCSV(t *testing.T) {
 4,37.966625,23.728263,1405594974`,
 				[][]calculator.RidePart{
 					{
-						{RideID: 1, Coordinate: calculator.Coordinate{Latitude: 37.966660, Longitude: 23.728308}, Timestamp: 1405594957},
-						{RideID: 1, Coordinate: calculator.Coordinate{Latitude: 37.966627, Longitude: 23.728263}, Timestamp: 1405594966},
-						{RideID: 1, Coordinate: calculator.Coordinate{Latitude: 37.966625, Longitude: 23.728263}, Timestamp: 1405594974},
-						{1, calculator.Coordinate{Latitude: 37.966613, Longitude: 23.728375}, 1405594984},
+						{RideID: 1, Coordinate: calculator.Coordinate{Latitude: 37.966660, Longitude: 23.728308}, Timestamp: 1405594957000000000},
+						{RideID: 1, Coordinate: calculator.Coordinate{Latitude: 37.966627, Longitude: 23.728263}, Timestamp: 1405594966000000000},
+						{RideID: 1, Coordinate: calculator.Coordinate{Latitude: 37.966625, Longitude: 23.728263}, Timestamp: 1405594974000000000},
+						{RideID: 1, Coordinate: calculator.Coordinate{Latitude: 37.966613, Longitude: 23.728375}, Timestamp: 1405594984000000000},
 					}, {
-						{RideID: 2, Coordinate: calculator.Coordinate{Latitude: 37.966627, Longitude: 23.728263}, Timestamp: 1405594966},
-						{RideID: 2, Coordinate: calculator.Coordinate{Latitude: 37.966625, Longitude: 23.728263}, Timestamp: 1405594974},
-						{RideID: 2, Coordinate: calculator.Coordinate{Latitude: 37.966613, Longitude: 23.728375}, Timestamp: 1405594984},
+						{RideID: 2, Coordinate: calculator.Coordinate{Latitude: 37.966627, Longitude: 23.728263}, Timestamp: 1405594966000000000},
+						{RideID: 2, Coordinate: calculator.Coordinate{Latitude: 37.966625, Longitude: 23.728263}, Timestamp: 1405594974000000000},
+						{RideID: 2, Coordinate: calculator.Coordinate{Latitude: 37.966613, Longitude: 23.728375}, Timestamp: 1405594984000000000},
 					}, {
-						{RideID: 3, Coordinate: calculator.Coordinate{Latitude: 37.966627, Longitude: 23.728263}, Timestamp: 1405594966},
-						{RideID: 3, Coordinate: calculator.Coordinate{Latitude: 37.966625, Longitude: 23.728263}, Timestamp: 1405594974},
+						{RideID: 3, Coordinate: calculator.Coordinate{Latitude: 37.966627, Longitude: 23.728263}, Timestamp: 1405594966000000000},
+						{RideID: 3, Coordinate: calculator.Coordinate{Latitude: 37.966625, Longitude: 23.728263}, Timestamp: 1405594974000000000},
 					}, {
-						{RideID: 4, Coordinate: calculator.Coordinate{Latitude: 37.966625, Longitude: 23.728263}, Timestamp: 1405594974},
+						{RideID: 4, Coordinate: calculator.Coordinate{Latitude: 37.966625, Longitude: 23.728263}, Timestamp: 1405594974000000000},
 					},
 				},
 			},
@@ -86,7 +88,9 @@ func TestParseInputCSV(t *testing.T) {
 
 			input := strings.NewReader(tt.args.csvData)
 
-			ParseInputCSV(input, tt.args.channel)
+			if err := ParseInputCSV(input, tt.args.channel, Options{}); err != nil {
+				t.Errorf("ParseInputCSV() returned error %v", err)
+			}
 
 			got := make([][]calculator.RidePart, 0, 10)
 
@@ -109,3 +113,112 @@ func TestParseInputCSV(t *testing.T) {
 		})
 	}
 }
+
+func TestParseInputCSV_rejects(t *testing.T) {
+	csvData := `1,37.966660,23.728308,1405594957
+1,37.966627,abc,1405594966
+1,37.966625,23.728263
+1,"37.966613,23.728375,1405594984
+`
+	type want struct {
+		rides   [][]calculator.RidePart
+		rejects string
+		lines   []int
+		err     error
+	}
+	tests := []struct {
+		name       string
+		maxRejects int
+		want       want
+	}{
+		{
+			"rejects within the budget are skipped",
+			-1,
+			want{
+				[][]calculator.RidePart{{
+					{RideID: 1, Coordinate: calculator.Coordinate{Latitude: 37.966660, Longitude: 23.728308}, Timestamp: 1405594957000000000},
+				}},
+				"line_number,raw_row,reason\n" +
+					"2,\"1,37.966627,abc,1405594966\",\"invalid lng \"\"abc\"\"\"\n" +
+					"3,\"1,37.966625,23.728263\",\"expected 4 fields, got 3\"\n" +
+					"4,\"1,\"\"37.966613,23.728375,1405594984\",\"parse error on line 4, column 35: extraneous or missing \"\" in quoted-field\"\n",
+				[]int{2, 3, 4},
+				nil,
+			},
+		},
+		{
+			"exceeding the budget returns an error",
+			1,
+			want{
+				[][]calculator.RidePart{},
+				"line_number,raw_row,reason\n" +
+					"2,\"1,37.966627,abc,1405594966\",\"invalid lng \"\"abc\"\"\"\n" +
+					"3,\"1,37.966625,23.728263\",\"expected 4 fields, got 3\"\n",
+				[]int{2, 3},
+				ErrTooManyRejects,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			channel := make(chan []calculator.RidePart, 10)
+			rejects := bytes.NewBufferString("")
+			var lines []int
+			onReject := func(lineNumber int, _ string, _ error) { lines = append(lines, lineNumber) }
+
+			err := ParseInputCSV(strings.NewReader(csvData), channel, Options{Rejects: rejects, OnReject: onReject, MaxRejects: tt.maxRejects})
+			close(channel)
+
+			if !errors.Is(err, tt.want.err) {
+				t.Errorf("ParseInputCSV() returned error %v, want %v", err, tt.want.err)
+			}
+
+			got := make([][]calculator.RidePart, 0, 10)
+			for rides := range channel {
+				got = append(got, rides)
+			}
+
+			if !reflect.DeepEqual(got, tt.want.rides) {
+				t.Errorf("ParseInputCSV() pushed to channel %v, want %v", got, tt.want.rides)
+			}
+			if rejects.String() != tt.want.rejects {
+				t.Errorf("ParseInputCSV() rejects = `%v`, want `%v`", rejects.String(), tt.want.rejects)
+			}
+			if !reflect.DeepEqual(lines, tt.want.lines) {
+				t.Errorf("ParseInputCSV() called OnReject with lines %v, want %v", lines, tt.want.lines)
+			}
+		})
+	}
+}
+
+func TestParseEntry(t *testing.T) {
+	tests := []struct {
+		name    string
+		line    []string
+		want    calculator.RidePart
+		wantErr bool
+	}{
+		{"valid line", []string{"1", "37.96666", "23.728308", "1405594957"},
+			calculator.RidePart{RideID: 1, Coordinate: calculator.Coordinate{Latitude: 37.96666, Longitude: 23.728308}, Timestamp: 1405594957000000000}, false},
+		{"invalid id", []string{"x", "37.96666", "23.728308", "1405594957"}, calculator.RidePart{}, true},
+		{"id above 2^31", []string{"2147483648", "37.96666", "23.728308", "1405594957"},
+			calculator.RidePart{RideID: 2147483648, Coordinate: calculator.Coordinate{Latitude: 37.96666, Longitude: 23.728308}, Timestamp: 1405594957000000000}, false},
+		{"largest 64-bit id", []string{"9223372036854775807", "37.96666", "23.728308", "1405594957"},
+			calculator.RidePart{RideID: 9223372036854775807, Coordinate: calculator.Coordinate{Latitude: 37.96666, Longitude: 23.728308}, Timestamp: 1405594957000000000}, false},
+		{"id above 2^63", []string{"9223372036854775808", "37.96666", "23.728308", "1405594957"}, calculator.RidePart{}, true},
+		{"timestamp after 2038-01-19T03:14:07Z", []string{"1", "37.96666", "23.728308", "2147483648"},
+			calculator.RidePart{RideID: 1, Coordinate: calculator.Coordinate{Latitude: 37.96666, Longitude: 23.728308}, Timestamp: 2147483648000000000}, false},
+		{"latitude out of range", []string{"1", "97.96666", "23.728308", "1405594957"}, calculator.RidePart{}, true},
+		{"longitude out of range", []string{"1", "37.96666", "-183.7", "1405594957"}, calculator.RidePart{}, true},
+		{"NaN latitude", []string{"1", "NaN", "23.728308", "1405594957"}, calculator.RidePart{}, true},
+		{"empty timestamp", []string{"1", "37.96666", "23.728308", ""}, calculator.RidePart{}, true},
+		{"too many fields", []string{"1", "37.96666", "23.728308", "1405594957", "5"}, calculator.RidePart{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, err := parseEntry(tt.line, defaultColumnIndexes, TimestampSeconds); (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("parseEntry() = %v, %v, want %v, error: %v", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,29 @@
+package parser
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// enumNames are the names of the values of an enum, indexed by value, for its String and Set methods, so that it can
+// be used as a flag.Value
+type enumNames []string
+
+// name returns the name of the value, or its number when it has none
+func (names enumNames) name(value int) string {
+	if value < 0 || value >= len(names) {
+		return strconv.Itoa(value)
+	}
+	return names[value]
+}
+
+// parse returns the value of the name, in any case, or an error listing the names of the values of the kind of enum
+func (names enumNames) parse(kind string, name string) (int, error) {
+	for i, candidate := range names {
+		if strings.EqualFold(name, candidate) {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown %s %q, expected one of %s", kind, name, strings.Join(names, ", "))
+}
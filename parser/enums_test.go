@@ -0,0 +1,36 @@
+package parser
+
+import (
+	"testing"
+)
+
+func TestEnumNames(t *testing.T) {
+	names := enumNames{"auto", "csv"}
+
+	tests := []struct {
+		name     string
+		text     string
+		want     int
+		wantErr  string
+		wantName string
+	}{
+		{"first value", "auto", 0, "", "auto"},
+		{"name in another case", "CSV", 1, "", "csv"},
+		{"unknown name", "xml", 0, `unknown format "xml", expected one of auto, csv`, "auto"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := names.parse("format", tt.text)
+			if got != tt.want || (err == nil) != (tt.wantErr == "") || (err != nil && err.Error() != tt.wantErr) {
+				t.Errorf("parse() = %v, %v, want %v, %q", got, err, tt.want, tt.wantErr)
+			}
+			if name := names.name(got); name != tt.wantName {
+				t.Errorf("name() = %v, want %v", name, tt.wantName)
+			}
+		})
+	}
+
+	if name := names.name(7); name != "7" {
+		t.Errorf("name() of a value without a name = %v, want 7", name)
+	}
+}
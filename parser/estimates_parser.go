@@ -0,0 +1,123 @@
+package parser
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// ParseEstimates reads the estimates written by the estimate command, as CSV or JSON Lines, into a map of fare
+// estimates by ride id. The columns after fare_estimate, such as the breakdown and the status, are ignored, as are a
+// CSV header and the failed rides, whose estimate is empty. Parquet estimates are not supported
+// An error is returned for the first malformed line, or for a ride id found twice
+func ParseEstimates(file io.Reader, format Format) (map[int64]float64, error) {
+	switch format {
+	case FormatParquet:
+		return nil, fmt.Errorf("parquet estimates are not supported")
+	case FormatJSONL:
+		return parseEstimatesJSONL(file)
+	default:
+		return parseEstimatesCSV(file)
+	}
+}
+
+func parseEstimatesCSV(file io.Reader) (map[int64]float64, error) {
+	result := make(map[int64]float64)
+
+	reader := csv.NewReader(file)
+
+	for lineNumber := 1; ; lineNumber++ {
+		line, err := reader.Read()
+
+		if err == io.EOF {
+			return result, nil
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		if len(line) < 2 {
+			return nil, fmt.Errorf("line %d: expected id_ride and fare_estimate, got %d fields", lineNumber, len(line))
+		}
+
+		id, err := strconv.ParseInt(line[0], 10, 64)
+		if err != nil {
+			if lineNumber == 1 {
+				continue
+			}
+			return nil, fmt.Errorf("line %d: invalid ride id %q", lineNumber, line[0])
+		}
+
+		if line[1] == "" {
+			continue
+		}
+
+		estimate, err := strconv.ParseFloat(line[1], 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid fare estimate %q", lineNumber, line[1])
+		}
+
+		if err := addEstimate(result, id, estimate); err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNumber, err)
+		}
+	}
+}
+
+// jsonEstimate is the part of an estimate JSON object read by ParseEstimates
+type jsonEstimate struct {
+	RideID   *int64   `json:"id_ride"`
+	Estimate *float64 `json:"fare_estimate"`
+}
+
+func parseEstimatesJSONL(file io.Reader) (map[int64]float64, error) {
+	result := make(map[int64]float64)
+
+	reader := bufio.NewReader(file)
+
+	for lineNumber := 1; ; lineNumber++ {
+		line, err := reader.ReadBytes('\n')
+
+		if err == io.EOF && len(line) == 0 {
+			return result, nil
+		}
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+
+		var estimate jsonEstimate
+		if err := json.Unmarshal(line, &estimate); err != nil {
+			return nil, fmt.Errorf("line %d: invalid JSON object: %w", lineNumber, err)
+		}
+
+		if estimate.RideID == nil {
+			return nil, fmt.Errorf("line %d: missing id_ride", lineNumber)
+		}
+		if estimate.Estimate == nil {
+			continue
+		}
+
+		if err := addEstimate(result, *estimate.RideID, *estimate.Estimate); err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNumber, err)
+		}
+	}
+}
+
+// addEstimate adds the estimate of the ride to the estimates, unless the ride already has one
+func addEstimate(estimates map[int64]float64, id int64, estimate float64) error {
+	if _, found := estimates[id]; found {
+		return fmt.Errorf("duplicate ride id %d", id)
+	}
+
+	estimates[id] = estimate
+	return nil
+}
@@ -0,0 +1,90 @@
+package parser
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseEstimates(t *testing.T) {
+	type want struct {
+		res     map[int64]float64
+		wantErr bool
+	}
+	tests := []struct {
+		name   string
+		format Format
+		data   string
+		want   want
+	}{
+		{
+			"no estimates",
+			FormatCSV,
+			"",
+			want{map[int64]float64{}, false},
+		},
+		{
+			"2 CSV estimates",
+			FormatCSV,
+			"1,27.39\n2,3.47\n",
+			want{map[int64]float64{1: 27.39, 2: 3.47}, false},
+		},
+		{
+			"CSV estimates with a breakdown header and a status column, skipping the failed rides",
+			FormatCSV,
+			"id_ride,fare_estimate,flag,status\n1,27.39,1.30,ok\n2,,,not_enough_segments\n",
+			want{map[int64]float64{1: 27.39}, false},
+		},
+		{
+			"JSON Lines estimates, skipping blank lines and the failed rides",
+			FormatJSONL,
+			`{"id_ride":1,"fare_estimate":27.39,"status":"ok"}` + "\n\n" + `{"id_ride":2,"status":"not_enough_segments"}` + "\n" +
+				`{"id_ride":3,"fare_estimate":3.47,"breakdown":{"flag":1.3}}`,
+			want{map[int64]float64{1: 27.39, 3: 3.47}, false},
+		},
+		{
+			"invalid ride id after the first line returns an error",
+			FormatCSV,
+			"1,27.39\none,3.47\n",
+			want{nil, true},
+		},
+		{
+			"invalid fare estimate returns an error",
+			FormatCSV,
+			"1,twenty\n",
+			want{nil, true},
+		},
+		{
+			"duplicate ride id returns an error",
+			FormatCSV,
+			"1,27.39\n1,3.47\n",
+			want{nil, true},
+		},
+		{
+			"invalid JSON returns an error",
+			FormatJSONL,
+			`{"id_ride":1,`,
+			want{nil, true},
+		},
+		{
+			"JSON object without a ride id returns an error",
+			FormatJSONL,
+			`{"fare_estimate":3.47}`,
+			want{nil, true},
+		},
+		{
+			"parquet returns an error",
+			FormatParquet,
+			"",
+			want{nil, true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseEstimates(strings.NewReader(tt.data), tt.format)
+			if (err != nil) != tt.want.wantErr || !reflect.DeepEqual(got, tt.want.res) {
+				t.Errorf("ParseEstimates() = %v, %v, want %v, error: %v", got, err, tt.want.res, tt.want.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package parser
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// Format is the format of an input or output file
+type Format int
+
+const (
+	// FormatAuto detects the format by the extension of the file, ignoring a .gz or .zst extension, and is CSV by default
+	FormatAuto Format = iota
+	// FormatCSV is comma separated values
+	FormatCSV
+	// FormatJSONL is JSON Lines, a JSON object per line, detected by the .jsonl and .ndjson extensions
+	FormatJSONL
+	// FormatParquet is Apache Parquet, detected by the .parquet extension
+	FormatParquet
+)
+
+var formatNames = enumNames{"auto", "csv", "jsonl", "parquet"}
+
+// String returns the name of the format
+func (format Format) String() string {
+	return formatNames.name(int(format))
+}
+
+// Set sets the format by its name, so that Format can be used as a flag.Value
+func (format *Format) Set(name string) error {
+	value, err := formatNames.parse("format", name)
+	if err != nil {
+		return err
+	}
+
+	*format = Format(value)
+	return nil
+}
+
+// Resolve returns the format, detecting it by the extension of the path when it is FormatAuto
+func (format Format) Resolve(path string) Format {
+	if format != FormatAuto {
+		return format
+	}
+
+	path = strings.ToLower(path)
+	path = strings.TrimSuffix(strings.TrimSuffix(path, ".gz"), ".zst")
+
+	switch filepath.Ext(path) {
+	case ".jsonl", ".ndjson":
+		return FormatJSONL
+	case ".parquet":
+		return FormatParquet
+	default:
+		return FormatCSV
+	}
+}
@@ -0,0 +1,52 @@
+package parser
+
+import "testing"
+
+func TestFormat_Resolve(t *testing.T) {
+	type args struct {
+		format Format
+		path   string
+	}
+	tests := []struct {
+		name string
+		args args
+		want Format
+	}{
+		{"csv extension", args{FormatAuto, "rides.csv"}, FormatCSV},
+		{"jsonl extension", args{FormatAuto, "rides.jsonl"}, FormatJSONL},
+		{"compressed ndjson extension", args{FormatAuto, "RIDES.NDJSON.gz"}, FormatJSONL},
+		{"parquet extension", args{FormatAuto, "rides.parquet"}, FormatParquet},
+		{"unknown extension", args{FormatAuto, "rides.txt"}, FormatCSV},
+		{"standard stream", args{FormatAuto, "-"}, FormatCSV},
+		{"explicit format", args{FormatJSONL, "rides.csv"}, FormatJSONL},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.args.format.Resolve(tt.args.path); got != tt.want {
+				t.Errorf("Format.Resolve() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormat_Set(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    Format
+		wantErr bool
+	}{
+		{"auto", FormatAuto, false},
+		{"CSV", FormatCSV, false},
+		{"jsonl", FormatJSONL, false},
+		{"parquet", FormatParquet, false},
+		{"xml", FormatAuto, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got Format
+			if err := got.Set(tt.name); got != tt.want || (err != nil) != tt.wantErr {
+				t.Errorf("Format.Set() = %v, %v, want %v, error: %v", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,342 @@
+package parser
+
+import (
+	"container/list"
+	"encoding/binary"
+	"fmt"
+	"harry-pap/beat_assignment/calculator"
+	"io"
+	"math"
+	"os"
+	"time"
+)
+
+// Grouping is how the lines of the input are grouped into rides
+type Grouping int
+
+const (
+	// GroupContiguous expects the lines of each ride to be contiguous, and completes a ride when the ride id changes
+	GroupContiguous Grouping = iota
+	// GroupMultiplexed buffers the lines of interleaved rides, and completes a ride on an end-of-ride marker,
+	// after an inactivity gap, or at the end of the input
+	GroupMultiplexed
+)
+
+var groupingNames = enumNames{"contiguous", "multiplexed"}
+
+// String returns the name of the grouping
+func (grouping Grouping) String() string {
+	return groupingNames.name(int(grouping))
+}
+
+// Set sets the grouping by its name, so that Grouping can be used as a flag.Value
+func (grouping *Grouping) Set(name string) error {
+	value, err := groupingNames.parse("grouping", name)
+	if err != nil {
+		return err
+	}
+
+	*grouping = Grouping(value)
+	return nil
+}
+
+// grouper groups the ride parts of the input into rides
+type grouper interface {
+	// add adds a ride part, which is the last one of its ride when end is true, and returns the completed rides
+	add(entry calculator.RidePart, end bool) ([][]calculator.RidePart, error)
+	// flush returns the rides that are not completed yet, at the end of the input
+	flush() ([][]calculator.RidePart, error)
+	// close releases the resources of the grouper
+	close() error
+}
+
+func newGrouper(options Options) grouper {
+	if options.Grouping == GroupMultiplexed {
+		return newMultiplexedGrouper(options.InactivityGap, options.MaxOpenRides, options.SpillDir)
+	}
+	return &contiguousGrouper{}
+}
+
+// contiguousGrouper completes a ride when the ride id changes, or on its end-of-ride marker
+type contiguousGrouper struct {
+	ride []calculator.RidePart
+}
+
+func (grouper *contiguousGrouper) add(entry calculator.RidePart, end bool) ([][]calculator.RidePart, error) {
+	var result [][]calculator.RidePart
+
+	if len(grouper.ride) > 0 && grouper.ride[0].RideID != entry.RideID {
+		result = append(result, grouper.ride)
+		grouper.ride = nil
+	}
+
+	if grouper.ride == nil {
+		grouper.ride = make([]calculator.RidePart, 0, 512)
+	}
+	grouper.ride = append(grouper.ride, entry)
+
+	if end {
+		result = append(result, grouper.ride)
+		grouper.ride = nil
+	}
+
+	return result, nil
+}
+
+func (grouper *contiguousGrouper) flush() ([][]calculator.RidePart, error) {
+	if len(grouper.ride) == 0 {
+		return nil, nil
+	}
+
+	result := [][]calculator.RidePart{grouper.ride}
+	grouper.ride = nil
+
+	return result, nil
+}
+
+func (grouper *contiguousGrouper) close() error {
+	return nil
+}
+
+// multiplexedGrouper buffers the parts of every open ride. When more than maxOpenRides rides have parts buffered in
+// memory, the parts of the least recently updated one are spilled to a temporary file, and read back when it completes
+type multiplexedGrouper struct {
+	inactivityGap int64
+	maxOpenRides  int
+	spillDir      string
+
+	rides    map[int64]*openRide
+	activity *list.List
+	inMemory *list.List
+	spill    *spillFile
+}
+
+type openRide struct {
+	id            int64
+	parts         []calculator.RidePart
+	spilled       []spillChunk
+	lastTimestamp int64
+
+	activityElement *list.Element
+	inMemoryElement *list.Element
+}
+
+func newMultiplexedGrouper(inactivityGap time.Duration, maxOpenRides int, spillDir string) *multiplexedGrouper {
+	return &multiplexedGrouper{
+		inactivityGap: int64(inactivityGap),
+		maxOpenRides:  maxOpenRides,
+		spillDir:      spillDir,
+		rides:         make(map[int64]*openRide),
+		activity:      list.New(),
+		inMemory:      list.New(),
+	}
+}
+
+func (grouper *multiplexedGrouper) add(entry calculator.RidePart, end bool) ([][]calculator.RidePart, error) {
+	result, err := grouper.completeInactive(entry.Timestamp)
+	if err != nil {
+		return nil, err
+	}
+
+	ride, found := grouper.rides[entry.RideID]
+	if !found {
+		ride = &openRide{id: entry.RideID}
+		ride.activityElement = grouper.activity.PushBack(ride)
+		grouper.rides[entry.RideID] = ride
+	} else {
+		grouper.activity.MoveToBack(ride.activityElement)
+	}
+
+	if ride.inMemoryElement == nil {
+		ride.inMemoryElement = grouper.inMemory.PushBack(ride)
+	} else {
+		grouper.inMemory.MoveToBack(ride.inMemoryElement)
+	}
+
+	ride.parts = append(ride.parts, entry)
+	ride.lastTimestamp = entry.Timestamp
+
+	if end {
+		completed, err := grouper.complete(ride)
+		if err != nil {
+			return nil, err
+		}
+		return append(result, completed), nil
+	}
+
+	if grouper.maxOpenRides > 0 && grouper.inMemory.Len() > grouper.maxOpenRides {
+		if err := grouper.spillLeastRecent(); err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+// completeInactive completes the rides whose last part is more than the inactivity gap before the given timestamp
+func (grouper *multiplexedGrouper) completeInactive(timestamp int64) ([][]calculator.RidePart, error) {
+	var result [][]calculator.RidePart
+
+	if grouper.inactivityGap <= 0 {
+		return nil, nil
+	}
+
+	for element := grouper.activity.Front(); element != nil; {
+		ride := element.Value.(*openRide)
+		element = element.Next()
+
+		if timestamp-ride.lastTimestamp <= grouper.inactivityGap {
+			break
+		}
+
+		completed, err := grouper.complete(ride)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, completed)
+	}
+
+	return result, nil
+}
+
+func (grouper *multiplexedGrouper) complete(ride *openRide) ([]calculator.RidePart, error) {
+	delete(grouper.rides, ride.id)
+	grouper.activity.Remove(ride.activityElement)
+	if ride.inMemoryElement != nil {
+		grouper.inMemory.Remove(ride.inMemoryElement)
+	}
+
+	if len(ride.spilled) == 0 {
+		return ride.parts, nil
+	}
+
+	parts, err := grouper.spill.read(ride.spilled)
+	if err != nil {
+		return nil, err
+	}
+	return append(parts, ride.parts...), nil
+}
+
+func (grouper *multiplexedGrouper) spillLeastRecent() error {
+	ride := grouper.inMemory.Remove(grouper.inMemory.Front()).(*openRide)
+	ride.inMemoryElement = nil
+
+	if grouper.spill == nil {
+		spill, err := newSpillFile(grouper.spillDir)
+		if err != nil {
+			return err
+		}
+		grouper.spill = spill
+	}
+
+	chunk, err := grouper.spill.write(ride.parts)
+	if err != nil {
+		return err
+	}
+
+	ride.spilled = append(ride.spilled, chunk)
+	ride.parts = nil
+
+	return nil
+}
+
+func (grouper *multiplexedGrouper) flush() ([][]calculator.RidePart, error) {
+	result := make([][]calculator.RidePart, 0, len(grouper.rides))
+
+	for grouper.activity.Len() > 0 {
+		completed, err := grouper.complete(grouper.activity.Front().Value.(*openRide))
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, completed)
+	}
+
+	return result, nil
+}
+
+func (grouper *multiplexedGrouper) close() error {
+	if grouper.spill == nil {
+		return nil
+	}
+	return grouper.spill.close()
+}
+
+// spillRecordSize is the size of a spilled ride part: its ride id, latitude, longitude and timestamp, 8 bytes each
+const spillRecordSize = 32
+
+type spillChunk struct {
+	offset int64
+	count  int
+}
+
+// spillFile is a temporary file, to which the ride parts are appended as fixed size records
+type spillFile struct {
+	file *os.File
+	size int64
+}
+
+func newSpillFile(dir string) (*spillFile, error) {
+	file, err := os.CreateTemp(dir, "fare-calculator-spill-*")
+	if err != nil {
+		return nil, fmt.Errorf("cannot create spill file: %w", err)
+	}
+	return &spillFile{file: file}, nil
+}
+
+func (spill *spillFile) write(parts []calculator.RidePart) (spillChunk, error) {
+	buffer := make([]byte, len(parts)*spillRecordSize)
+
+	for i, part := range parts {
+		record := buffer[i*spillRecordSize:]
+		binary.LittleEndian.PutUint64(record[0:], uint64(part.RideID))
+		binary.LittleEndian.PutUint64(record[8:], math.Float64bits(part.Coordinate.Latitude))
+		binary.LittleEndian.PutUint64(record[16:], math.Float64bits(part.Coordinate.Longitude))
+		binary.LittleEndian.PutUint64(record[24:], uint64(part.Timestamp))
+	}
+
+	if _, err := spill.file.WriteAt(buffer, spill.size); err != nil {
+		return spillChunk{}, fmt.Errorf("cannot write spill file: %w", err)
+	}
+
+	chunk := spillChunk{offset: spill.size, count: len(parts)}
+	spill.size += int64(len(buffer))
+
+	return chunk, nil
+}
+
+func (spill *spillFile) read(chunks []spillChunk) ([]calculator.RidePart, error) {
+	count := 0
+	for _, chunk := range chunks {
+		count += chunk.count
+	}
+	result := make([]calculator.RidePart, 0, count)
+
+	for _, chunk := range chunks {
+		buffer := make([]byte, chunk.count*spillRecordSize)
+		if _, err := spill.file.ReadAt(buffer, chunk.offset); err != nil && err != io.EOF {
+			return nil, fmt.Errorf("cannot read spill file: %w", err)
+		}
+
+		for i := 0; i < chunk.count; i++ {
+			record := buffer[i*spillRecordSize:]
+			result = append(result, calculator.RidePart{
+				RideID: int64(binary.LittleEndian.Uint64(record[0:])),
+				Coordinate: calculator.Coordinate{
+					Latitude:  math.Float64frombits(binary.LittleEndian.Uint64(record[8:])),
+					Longitude: math.Float64frombits(binary.LittleEndian.Uint64(record[16:])),
+				},
+				Timestamp: int64(binary.LittleEndian.Uint64(record[24:])),
+			})
+		}
+	}
+
+	return result, nil
+}
+
+func (spill *spillFile) close() error {
+	closeErr := spill.file.Close()
+	if err := os.Remove(spill.file.Name()); err != nil {
+		return err
+	}
+	return closeErr
+}
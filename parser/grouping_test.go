@@ -0,0 +1,174 @@
+package parser
+
+import (
+	"harry-pap/beat_assignment/calculator"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseInputCSV_grouping(t *testing.T) {
+	type args struct {
+		options Options
+		csvData string
+	}
+	type want struct {
+		// rides contains the timestamps in seconds of the parts of each pushed ride
+		rides   [][]int64
+		ids     []int64
+		wantErr bool
+	}
+	tests := []struct {
+		name string
+		args args
+		want want
+	}{
+		{
+			"contiguous rides",
+			args{Options{}, "1,37.9,23.7,10\n1,37.9,23.7,20\n2,37.9,23.7,15\n"},
+			want{[][]int64{{10, 20}, {15}}, []int64{1, 2}, false},
+		},
+		{
+			"contiguous rides with an end-of-ride marker",
+			args{Options{Columns: ColumnMapping{End: "4"}}, "1,37.9,23.7,10,\n1,37.9,23.7,20,end\n1,37.9,23.7,30,0\n"},
+			want{[][]int64{{10, 20}, {30}}, []int64{1, 1}, false},
+		},
+		{
+			"interleaved rides are completed at the end of the input, in order of their last part",
+			args{Options{Grouping: GroupMultiplexed}, "1,37.9,23.7,10\n2,37.9,23.7,11\n1,37.9,23.7,12\n2,37.9,23.7,13\n3,37.9,23.7,14\n1,37.9,23.7,15\n"},
+			want{[][]int64{{11, 13}, {14}, {10, 12, 15}}, []int64{2, 3, 1}, false},
+		},
+		{
+			"interleaved rides with end-of-ride markers",
+			args{Options{Grouping: GroupMultiplexed, Columns: ColumnMapping{End: "4"}},
+				"1,37.9,23.7,10,false\n2,37.9,23.7,11,false\n1,37.9,23.7,12,true\n2,37.9,23.7,13,false\n"},
+			want{[][]int64{{10, 12}, {11, 13}}, []int64{1, 2}, false},
+		},
+		{
+			"interleaved rides are completed after the inactivity gap, so that later parts start a new ride",
+			args{Options{Grouping: GroupMultiplexed, InactivityGap: time.Minute},
+				"1,37.9,23.7,0\n2,37.9,23.7,30\n2,37.9,23.7,90\n1,37.9,23.7,100\n2,37.9,23.7,120\n"},
+			want{[][]int64{{0}, {100}, {30, 90, 120}}, []int64{1, 1, 2}, false},
+		},
+		{
+			"malformed end-of-ride marker is rejected",
+			args{Options{MaxRejects: 0, Columns: ColumnMapping{End: "4"}}, "1,37.9,23.7,10,maybe\n"},
+			want{nil, nil, true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			channel := make(chan []calculator.RidePart, 10)
+
+			err := ParseInputCSV(strings.NewReader(tt.args.csvData), channel, tt.args.options)
+			close(channel)
+
+			if (err != nil) != tt.want.wantErr {
+				t.Fatalf("ParseInputCSV() returned error %v, want error: %v", err, tt.want.wantErr)
+			}
+			if tt.want.wantErr {
+				return
+			}
+
+			gotRides := make([][]int64, 0, 10)
+			gotIDs := make([]int64, 0, 10)
+			for ride := range channel {
+				gotIDs = append(gotIDs, ride[0].RideID)
+				timestamps := make([]int64, 0, len(ride))
+				for _, part := range ride {
+					if part.RideID != ride[0].RideID {
+						t.Errorf("ParseInputCSV() pushed ride %d with a part of ride %d", ride[0].RideID, part.RideID)
+					}
+					timestamps = append(timestamps, part.Timestamp/int64(time.Second))
+				}
+				gotRides = append(gotRides, timestamps)
+			}
+
+			if !reflect.DeepEqual(gotRides, tt.want.rides) || !reflect.DeepEqual(gotIDs, tt.want.ids) {
+				t.Errorf("ParseInputCSV() pushed rides %v %v, want %v %v", gotIDs, gotRides, tt.want.ids, tt.want.rides)
+			}
+		})
+	}
+}
+
+func TestMultiplexedGrouper_spill(t *testing.T) {
+	spillDir := t.TempDir()
+	grouper := newMultiplexedGrouper(0, 2, spillDir)
+
+	var got [][]calculator.RidePart
+	for i := int64(0); i < 40; i++ {
+		part := calculator.RidePart{
+			RideID:     i % 5,
+			Coordinate: calculator.Coordinate{Latitude: 37.9 + float64(i)/1000, Longitude: -23.7},
+			Timestamp:  i * int64(time.Second),
+		}
+		completed, err := grouper.add(part, i >= 35)
+		if err != nil {
+			t.Fatalf("multiplexedGrouper.add() returned error %v", err)
+		}
+		got = append(got, completed...)
+
+		if grouper.inMemory.Len() > 2 {
+			t.Fatalf("multiplexedGrouper.add() kept %d rides in memory, want at most 2", grouper.inMemory.Len())
+		}
+	}
+
+	if grouper.spill == nil {
+		t.Fatalf("multiplexedGrouper.add() did not spill to disk")
+	}
+
+	completed, err := grouper.flush()
+	if err != nil {
+		t.Fatalf("multiplexedGrouper.flush() returned error %v", err)
+	}
+	got = append(got, completed...)
+
+	if err := grouper.close(); err != nil {
+		t.Fatalf("multiplexedGrouper.close() returned error %v", err)
+	}
+	if entries, _ := os.ReadDir(spillDir); len(entries) != 0 {
+		t.Errorf("multiplexedGrouper.close() left %d files in the spill directory", len(entries))
+	}
+
+	if len(got) != 5 {
+		t.Fatalf("multiplexedGrouper completed %d rides, want 5", len(got))
+	}
+	for _, ride := range got {
+		if len(ride) != 8 {
+			t.Errorf("multiplexedGrouper completed ride %d with %d parts, want 8", ride[0].RideID, len(ride))
+		}
+		for i, part := range ride {
+			i := int64(i)
+			want := calculator.RidePart{
+				RideID:     ride[0].RideID,
+				Coordinate: calculator.Coordinate{Latitude: 37.9 + float64(i*5+ride[0].RideID)/1000, Longitude: -23.7},
+				Timestamp:  (i*5 + ride[0].RideID) * int64(time.Second),
+			}
+			if part != want {
+				t.Errorf("multiplexedGrouper completed ride %d with part %v, want %v", ride[0].RideID, part, want)
+			}
+		}
+	}
+}
+
+func TestGrouping_Set(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    Grouping
+		wantErr bool
+	}{
+		{"contiguous", GroupContiguous, false},
+		{"Multiplexed", GroupMultiplexed, false},
+		{"shuffled", GroupContiguous, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got Grouping
+			if err := got.Set(tt.name); got != tt.want || (err != nil) != tt.wantErr {
+				t.Errorf("Grouping.Set() = %v, %v, want %v, error: %v", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package parser
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"harry-pap/beat_assignment/calculator"
+	"io"
+)
+
+// ParseInputJSONL reads newline-delimited JSON objects, one per RidePart, and groups them into rides like ParseInputCSV
+// The keys of the fields are id_ride, lat, lng and timestamp, unless they are mapped to other keys by name with
+// Options.Columns, and the end-of-ride marker is read when Options.Columns.End is set. The values can be JSON numbers,
+// strings or booleans, and are validated like the CSV fields. Unknown keys and blank lines are ignored
+func ParseInputJSONL(file io.Reader, channel chan []calculator.RidePart, options Options) error {
+	return pushAndClose(NewJSONLSource(file, options), channel)
+}
+
+// jsonlEntryReader reads the entries of a JSON Lines input
+type jsonlEntryReader struct {
+	reader          *bufio.Reader
+	keys            []string
+	timestampFormat TimestampFormat
+	lineNumber      int
+}
+
+func (entries *jsonlEntryReader) read() (inputEntry, error) {
+	for {
+		line, err := entries.reader.ReadBytes('\n')
+
+		if err != nil && (err != io.EOF || len(line) == 0) {
+			return inputEntry{}, err
+		}
+
+		entries.lineNumber++
+		line = bytes.TrimSpace(line)
+
+		if len(line) == 0 {
+			continue
+		}
+
+		entry, err := entries.parse(line)
+		if err != nil {
+			return inputEntry{}, &rejectedLine{lineNumber: entries.lineNumber, raw: string(line), reason: err}
+		}
+		return entry, nil
+	}
+}
+
+func (entries *jsonlEntryReader) parse(line []byte) (inputEntry, error) {
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(line, &object); err != nil {
+		return inputEntry{}, fmt.Errorf("invalid JSON object: %w", err)
+	}
+
+	fields := make([]string, len(entries.keys))
+	for i, key := range entries.keys {
+		value, found := object[key]
+		if !found {
+			// the end-of-ride marker is optional
+			if i == len(defaultFieldNames)-1 {
+				continue
+			}
+			return inputEntry{}, fmt.Errorf("missing key %q", key)
+		}
+
+		text, err := jsonText(value)
+		if err != nil {
+			return inputEntry{}, fmt.Errorf("invalid %s: %w", key, err)
+		}
+		fields[i] = text
+	}
+
+	columns := columnIndexes{id: 0, latitude: 1, longitude: 2, timestamp: 3, end: -1, fields: len(fields)}
+	if len(fields) == len(defaultFieldNames) {
+		columns.end = len(defaultFieldNames) - 1
+	}
+
+	part, err := parseEntry(fields, columns, entries.timestampFormat)
+	if err != nil {
+		return inputEntry{}, err
+	}
+
+	end, err := parseEnd(fields, columns)
+	if err != nil {
+		return inputEntry{}, err
+	}
+
+	return inputEntry{part: part, end: end}, nil
+}
+
+// jsonText returns the text of a JSON string, number or boolean, and an empty text for null
+func jsonText(value json.RawMessage) (string, error) {
+	switch {
+	case bytes.Equal(value, []byte("null")):
+		return "", nil
+	case len(value) > 0 && value[0] == '"':
+		var text string
+		err := json.Unmarshal(value, &text)
+		return text, err
+	case len(value) > 0 && (value[0] == '{' || value[0] == '['):
+		return "", fmt.Errorf("unexpected value %s", value)
+	default:
+		return string(value), nil
+	}
+}
@@ -0,0 +1,104 @@
+package parser
+
+import (
+	"bytes"
+	"errors"
+	"harry-pap/beat_assignment/calculator"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseInputJSONL(t *testing.T) {
+	part1 := calculator.RidePart{RideID: 1, Coordinate: calculator.Coordinate{Latitude: 37.966660, Longitude: 23.728308}, Timestamp: 1405594957000000000}
+	part2 := calculator.RidePart{RideID: 1, Coordinate: calculator.Coordinate{Latitude: 37.966627, Longitude: 23.728263}, Timestamp: 1405594966000000000}
+	part3 := calculator.RidePart{RideID: 2, Coordinate: calculator.Coordinate{Latitude: 37.946545, Longitude: 23.754918}, Timestamp: 1405591065000000000}
+
+	type args struct {
+		options   Options
+		jsonlData string
+	}
+	type want struct {
+		rides   [][]calculator.RidePart
+		rejects string
+		err     error
+	}
+	tests := []struct {
+		name string
+		args args
+		want want
+	}{
+		{
+			"default keys",
+			args{Options{},
+				`{"id_ride": 1, "lat": 37.966660, "lng": 23.728308, "timestamp": 1405594957}` + "\n" +
+					`{"id_ride": 1, "lat": 37.966627, "lng": 23.728263, "timestamp": 1405594966, "driver_id": "d1"}` + "\n\n" +
+					`{"id_ride": 2, "lat": 37.946545, "lng": 23.754918, "timestamp": 1405591065}`},
+			want{[][]calculator.RidePart{{part1, part2}, {part3}}, "line_number,raw_row,reason\n", nil},
+		},
+		{
+			"string values and rfc3339 timestamps",
+			args{Options{TimestampFormat: TimestampAuto},
+				`{"id_ride": "1", "lat": "37.966660", "lng": "23.728308", "timestamp": "2014-07-17T11:02:37Z"}` + "\n" +
+					`{"id_ride": "1", "lat": "37.966627", "lng": "23.728263", "timestamp": 1405594966}` + "\n"},
+			want{[][]calculator.RidePart{{part1, part2}}, "line_number,raw_row,reason\n", nil},
+		},
+		{
+			"keys mapped by name, with end-of-ride marker",
+			args{Options{Columns: ColumnMapping{ID: "ride", Latitude: "latitude", Longitude: "longitude", Timestamp: "ts", End: "last"}},
+				`{"ride": 1, "latitude": 37.966660, "longitude": 23.728308, "ts": 1405594957}` + "\n" +
+					`{"ride": 1, "latitude": 37.966627, "longitude": 23.728263, "ts": 1405594966, "last": true}` + "\n" +
+					`{"ride": 1, "latitude": 37.966660, "longitude": 23.728308, "ts": 1405594957, "last": null}` + "\n"},
+			want{[][]calculator.RidePart{{part1, part2}, {part1}}, "line_number,raw_row,reason\n", nil},
+		},
+		{
+			"malformed lines are rejected",
+			args{Options{MaxRejects: -1},
+				`{"id_ride": 1, "lat": 37.966660, "lng": 23.728308, "timestamp": 1405594957}` + "\n" +
+					`{"id_ride": 1, "lat": 37.966627` + "\n" +
+					`{"id_ride": 1, "lat": 37.966627, "timestamp": 1405594966}` + "\n" +
+					`{"id_ride": 1, "lat": [37.966627], "lng": 23.728263, "timestamp": 1405594966}` + "\n" +
+					`{"id_ride": 1, "lat": 97.966627, "lng": 23.728263, "timestamp": 1405594966}` + "\n"},
+			want{
+				[][]calculator.RidePart{{part1}},
+				"line_number,raw_row,reason\n" +
+					"2,\"{\"\"id_ride\"\": 1, \"\"lat\"\": 37.966627\",invalid JSON object: unexpected end of JSON input\n" +
+					"3,\"{\"\"id_ride\"\": 1, \"\"lat\"\": 37.966627, \"\"timestamp\"\": 1405594966}\",\"missing key \"\"lng\"\"\"\n" +
+					"4,\"{\"\"id_ride\"\": 1, \"\"lat\"\": [37.966627], \"\"lng\"\": 23.728263, \"\"timestamp\"\": 1405594966}\",invalid lat: unexpected value [37.966627]\n" +
+					"5,\"{\"\"id_ride\"\": 1, \"\"lat\"\": 97.966627, \"\"lng\"\": 23.728263, \"\"timestamp\"\": 1405594966}\",\"invalid lat \"\"97.966627\"\"\"\n",
+				nil,
+			},
+		},
+		{
+			"too many malformed lines",
+			args{Options{}, `{"id_ride": 1}` + "\n"},
+			want{[][]calculator.RidePart{}, "line_number,raw_row,reason\n" + "1,\"{\"\"id_ride\"\": 1}\",\"missing key \"\"lat\"\"\"\n", ErrTooManyRejects},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			channel := make(chan []calculator.RidePart, 10)
+			rejects := bytes.NewBufferString("")
+			tt.args.options.Rejects = rejects
+
+			err := ParseInputJSONL(strings.NewReader(tt.args.jsonlData), channel, tt.args.options)
+			close(channel)
+
+			if !errors.Is(err, tt.want.err) {
+				t.Errorf("ParseInputJSONL() returned error %v, want %v", err, tt.want.err)
+			}
+
+			got := make([][]calculator.RidePart, 0, 10)
+			for rides := range channel {
+				got = append(got, rides)
+			}
+
+			if !reflect.DeepEqual(got, tt.want.rides) {
+				t.Errorf("ParseInputJSONL() pushed to channel %v, want %v", got, tt.want.rides)
+			}
+			if rejects.String() != tt.want.rejects {
+				t.Errorf("ParseInputJSONL() rejects = `%v`, want `%v`", rejects.String(), tt.want.rejects)
+			}
+		})
+	}
+}
@@ -0,0 +1,172 @@
+package parser
+
+import (
+	"fmt"
+	"harry-pap/beat_assignment/calculator"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// parquetBatchSize is the number of rows read from a row group at once
+const parquetBatchSize = 1024
+
+// ParseInputParquet reads the rows of a Parquet file, one row group at a time, and groups them into rides like
+// ParseInputCSV. The columns are looked up by name, like the keys of ParseInputJSONL, and can be of any numeric, string
+// or boolean type, validated like the CSV fields. A timestamp column of the TIMESTAMP logical type is read in its own
+// unit, and any other one with Options.TimestampFormat
+func ParseInputParquet(file io.ReaderAt, size int64, channel chan []calculator.RidePart, options Options) error {
+	source, err := NewParquetSource(file, size, options)
+	if err != nil {
+		return err
+	}
+
+	return pushAndClose(source, channel)
+}
+
+// parquetEntryReader reads the entries of a Parquet file
+type parquetEntryReader struct {
+	rowGroups []parquet.RowGroup
+	rows      parquet.Rows
+	buffer    []parquet.Row
+	position  int
+	count     int
+	rowNumber int
+
+	// fields maps the index of each mapped leaf column, to the index of its field
+	fields     map[int]int
+	fieldCount int
+	// timestampUnit is the unit of a timestamp column of the TIMESTAMP logical type, and 0 for any other column
+	timestampUnit   time.Duration
+	timestampFormat TimestampFormat
+}
+
+func newParquetEntryReader(file *parquet.File, options Options) (*parquetEntryReader, error) {
+	result := &parquetEntryReader{
+		rowGroups:       file.RowGroups(),
+		buffer:          make([]parquet.Row, parquetBatchSize),
+		fields:          make(map[int]int),
+		timestampFormat: options.TimestampFormat,
+	}
+
+	names := options.Columns.fieldNames()
+	result.fieldCount = len(names)
+
+	for i, name := range names {
+		column, found := lookupParquetColumn(file.Schema(), name)
+		if !found {
+			return nil, fmt.Errorf("%w: column %q is not in the schema %v", errColumnMapping, name, file.Schema().Columns())
+		}
+		result.fields[column.ColumnIndex] = i
+
+		if logicalType := column.Node.Type().LogicalType(); i == 3 && logicalType != nil && logicalType.Timestamp != nil {
+			switch unit := logicalType.Timestamp.Unit; {
+			case unit.Millis != nil:
+				result.timestampUnit = time.Millisecond
+			case unit.Micros != nil:
+				result.timestampUnit = time.Microsecond
+			default:
+				result.timestampUnit = time.Nanosecond
+			}
+			result.timestampFormat = TimestampRFC3339
+		}
+	}
+
+	return result, nil
+}
+
+// lookupParquetColumn returns the top level leaf column with the given name, ignoring case
+func lookupParquetColumn(schema *parquet.Schema, name string) (parquet.LeafColumn, bool) {
+	for _, path := range schema.Columns() {
+		if len(path) == 1 && strings.EqualFold(path[0], name) {
+			return schema.Lookup(path...)
+		}
+	}
+	return parquet.LeafColumn{}, false
+}
+
+func (entries *parquetEntryReader) read() (inputEntry, error) {
+	for entries.position == entries.count {
+		if err := entries.next(); err != nil {
+			return inputEntry{}, err
+		}
+	}
+
+	row := entries.buffer[entries.position]
+	entries.position++
+	entries.rowNumber++
+
+	fields := make([]string, entries.fieldCount)
+	for _, value := range row {
+		if i, found := entries.fields[value.Column()]; found {
+			fields[i] = entries.text(value, i)
+		}
+	}
+
+	columns := columnIndexes{id: 0, latitude: 1, longitude: 2, timestamp: 3, end: -1, fields: len(fields)}
+	if len(fields) == len(defaultFieldNames) {
+		columns.end = len(defaultFieldNames) - 1
+	}
+
+	part, err := parseEntry(fields, columns, entries.timestampFormat)
+	if err != nil {
+		return inputEntry{}, &rejectedLine{lineNumber: entries.rowNumber, raw: strings.Join(fields, ","), reason: err}
+	}
+
+	end, err := parseEnd(fields, columns)
+	if err != nil {
+		return inputEntry{}, &rejectedLine{lineNumber: entries.rowNumber, raw: strings.Join(fields, ","), reason: err}
+	}
+
+	return inputEntry{part: part, end: end}, nil
+}
+
+// next reads the next batch of rows, moving to the next row group when the current one is read.
+// io.EOF is returned after the last row group
+func (entries *parquetEntryReader) next() error {
+	if entries.rows == nil {
+		if len(entries.rowGroups) == 0 {
+			return io.EOF
+		}
+
+		entries.rows = entries.rowGroups[0].Rows()
+		entries.rowGroups = entries.rowGroups[1:]
+	}
+
+	count, err := entries.rows.ReadRows(entries.buffer)
+	entries.position, entries.count = 0, count
+
+	if err == io.EOF {
+		err = entries.rows.Close()
+		entries.rows = nil
+	}
+	return err
+}
+
+// text returns the text of a value, as it would be written in a CSV field
+func (entries *parquetEntryReader) text(value parquet.Value, field int) string {
+	if value.IsNull() {
+		return ""
+	}
+
+	switch value.Kind() {
+	case parquet.Boolean:
+		return strconv.FormatBool(value.Boolean())
+	case parquet.Int32:
+		return strconv.FormatInt(int64(value.Int32()), 10)
+	case parquet.Int64:
+		if field == 3 && entries.timestampUnit != 0 {
+			return time.Unix(0, value.Int64()*int64(entries.timestampUnit)).UTC().Format(time.RFC3339Nano)
+		}
+		return strconv.FormatInt(value.Int64(), 10)
+	case parquet.Float:
+		return strconv.FormatFloat(float64(value.Float()), 'f', -1, 32)
+	case parquet.Double:
+		return strconv.FormatFloat(value.Double(), 'f', -1, 64)
+	default:
+		return string(value.ByteArray())
+	}
+}
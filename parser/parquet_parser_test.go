@@ -0,0 +1,141 @@
+package parser
+
+import (
+	"bytes"
+	"errors"
+	"harry-pap/beat_assignment/calculator"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+type parquetPing struct {
+	Driver    string  `parquet:"driver_id"`
+	ID        int64   `parquet:"id_ride"`
+	Latitude  float64 `parquet:"lat"`
+	Longitude float64 `parquet:"lng"`
+	Timestamp int64   `parquet:"timestamp"`
+}
+
+type parquetTimePing struct {
+	Ride      string    `parquet:"ride"`
+	Latitude  float32   `parquet:"latitude"`
+	Longitude float32   `parquet:"longitude"`
+	Timestamp time.Time `parquet:"ts,timestamp(millisecond)"`
+	Last      bool      `parquet:"last"`
+}
+
+// writeParquet writes each batch of rows as a row group
+func writeParquet[T any](t *testing.T, batches ...[]T) *bytes.Reader {
+	buffer := bytes.NewBuffer(nil)
+	writer := parquet.NewGenericWriter[T](buffer)
+
+	for _, batch := range batches {
+		if _, err := writer.Write(batch); err != nil {
+			t.Fatalf("Write() returned error %v", err)
+		}
+		if err := writer.Flush(); err != nil {
+			t.Fatalf("Flush() returned error %v", err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Close() returned error %v", err)
+	}
+
+	return bytes.NewReader(buffer.Bytes())
+}
+
+func TestParseInputParquet(t *testing.T) {
+	part1 := calculator.RidePart{RideID: 1, Coordinate: calculator.Coordinate{Latitude: 37.96666, Longitude: 23.728308}, Timestamp: 1405594957000000000}
+	part2 := calculator.RidePart{RideID: 1, Coordinate: calculator.Coordinate{Latitude: 37.966627, Longitude: 23.728263}, Timestamp: 1405594966000000000}
+	part3 := calculator.RidePart{RideID: 2, Coordinate: calculator.Coordinate{Latitude: 37.946545, Longitude: 23.754918}, Timestamp: 1405591065000000000}
+
+	type args struct {
+		file    *bytes.Reader
+		options Options
+	}
+	type want struct {
+		rides   [][]calculator.RidePart
+		rejects string
+		err     error
+	}
+	tests := []struct {
+		name string
+		args args
+		want want
+	}{
+		{
+			"default columns, across row groups",
+			args{
+				writeParquet(t,
+					[]parquetPing{{"d1", 1, 37.96666, 23.728308, 1405594957}},
+					[]parquetPing{{"d1", 1, 37.966627, 23.728263, 1405594966}, {"d2", 2, 37.946545, 23.754918, 1405591065}}),
+				Options{},
+			},
+			want{[][]calculator.RidePart{{part1, part2}, {part3}}, "line_number,raw_row,reason\n", nil},
+		},
+		{
+			"columns mapped by name, with a timestamp column and end-of-ride marker",
+			args{
+				writeParquet(t, []parquetTimePing{
+					{"1", 37.5, 23.25, time.Unix(1405594957, 0), false},
+					{"1", 37.5, 23.25, time.Unix(1405594966, 0), true},
+					{"1", 37.5, 23.25, time.Unix(1405594970, 500000000), false},
+				}),
+				Options{Columns: ColumnMapping{ID: "ride", Latitude: "latitude", Longitude: "longitude", Timestamp: "ts", End: "last"}},
+			},
+			want{
+				[][]calculator.RidePart{
+					{
+						{RideID: 1, Coordinate: calculator.Coordinate{Latitude: 37.5, Longitude: 23.25}, Timestamp: 1405594957000000000},
+						{RideID: 1, Coordinate: calculator.Coordinate{Latitude: 37.5, Longitude: 23.25}, Timestamp: 1405594966000000000},
+					},
+					{{RideID: 1, Coordinate: calculator.Coordinate{Latitude: 37.5, Longitude: 23.25}, Timestamp: 1405594970500000000}},
+				},
+				"line_number,raw_row,reason\n",
+				nil,
+			},
+		},
+		{
+			"malformed rows are rejected",
+			args{
+				writeParquet(t, []parquetPing{{"d1", 1, 37.96666, 23.728308, 1405594957}, {"d1", 1, 97.5, 23.728263, 1405594966}}),
+				Options{MaxRejects: -1},
+			},
+			want{[][]calculator.RidePart{{part1}}, "line_number,raw_row,reason\n" + "2,\"1,97.5,23.728263,1405594966\",\"invalid lat \"\"97.5\"\"\"\n", nil},
+		},
+		{
+			"missing column",
+			args{writeParquet(t, []parquetPing{{"d1", 1, 37.96666, 23.728308, 1405594957}}), Options{Columns: ColumnMapping{ID: "ride"}}},
+			want{[][]calculator.RidePart{}, "", errColumnMapping},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			channel := make(chan []calculator.RidePart, 10)
+			rejects := bytes.NewBufferString("")
+			tt.args.options.Rejects = rejects
+
+			err := ParseInputParquet(tt.args.file, tt.args.file.Size(), channel, tt.args.options)
+			close(channel)
+
+			if !errors.Is(err, tt.want.err) {
+				t.Errorf("ParseInputParquet() returned error %v, want %v", err, tt.want.err)
+			}
+
+			got := make([][]calculator.RidePart, 0, 10)
+			for rides := range channel {
+				got = append(got, rides)
+			}
+
+			if !reflect.DeepEqual(got, tt.want.rides) {
+				t.Errorf("ParseInputParquet() pushed to channel %v, want %v", got, tt.want.rides)
+			}
+			if rejects.String() != tt.want.rejects {
+				t.Errorf("ParseInputParquet() rejects = `%v`, want `%v`", rejects.String(), tt.want.rejects)
+			}
+		})
+	}
+}
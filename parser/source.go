@@ -0,0 +1,197 @@
+package parser
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"harry-pap/beat_assignment/calculator"
+	"io"
+	"os"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// RideSource is a source of rides, each one with the ride parts of a single ride id
+type RideSource interface {
+	// Next returns the next ride, or io.EOF after the last one. Any other error stops the iteration
+	Next() ([]calculator.RidePart, error)
+	// Close releases the resources of the source, like its spill file, and flushes its rejects
+	Close() error
+}
+
+// NewCSVSource returns a RideSource reading the CSV input like ParseInputCSV
+func NewCSVSource(file io.Reader, options Options) RideSource {
+	raw := &rawRecorder{reader: file}
+	reader := csv.NewReader(raw)
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+
+	return newRideSource(&csvEntryReader{reader: reader, raw: raw, lines: lineParser{options: options}}, options)
+}
+
+// NewJSONLSource returns a RideSource reading the JSON Lines input like ParseInputJSONL
+func NewJSONLSource(file io.Reader, options Options) RideSource {
+	entries := &jsonlEntryReader{
+		reader:          bufio.NewReaderSize(file, 1<<16),
+		keys:            options.Columns.fieldNames(),
+		timestampFormat: options.TimestampFormat,
+	}
+
+	return newRideSource(entries, options)
+}
+
+// NewParquetSource returns a RideSource reading the Parquet file like ParseInputParquet
+func NewParquetSource(file io.ReaderAt, size int64, options Options) (RideSource, error) {
+	parquetFile, err := parquet.OpenFile(file, size)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := newParquetEntryReader(parquetFile, options)
+	if err != nil {
+		return nil, err
+	}
+
+	return newRideSource(entries, options), nil
+}
+
+// PushRides reads every ride of the source, and pushes it to the channel. The source is not closed
+// When the context is cancelled, it stops and returns the error of the context, with the number of rides pushed.
+// A read blocked on the input is only interrupted if the source reads it through compression.NewContextReader
+// Every 10,000 rides, a message is printed to standard error
+func PushRides(ctx context.Context, source RideSource, channel chan []calculator.RidePart) error {
+	var rideCounter int64
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("%w after reading %d rides", err, rideCounter)
+		}
+
+		ride, err := source.Next()
+
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil && ctx.Err() != nil {
+			// a source read through the context fails with its error, once it is cancelled
+			return fmt.Errorf("%w after reading %d rides", ctx.Err(), rideCounter)
+		}
+		if err != nil {
+			return err
+		}
+
+		select {
+		case channel <- ride:
+		case <-ctx.Done():
+			return fmt.Errorf("%w after reading %d rides", ctx.Err(), rideCounter)
+		}
+
+		rideCounter++
+
+		if rideCounter%10000 == 0 {
+			fmt.Fprintf(os.Stderr, "Processed %d rides\n", rideCounter)
+		}
+	}
+}
+
+// pushAndClose pushes the rides of the source to the channel, and closes the source
+func pushAndClose(source RideSource, channel chan []calculator.RidePart) error {
+	err := PushRides(context.Background(), source, channel)
+
+	if closeErr := source.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// entryReader reads the entries of an input format
+type entryReader interface {
+	// read returns the next entry, a *rejectedLine error for a malformed line, or io.EOF at the end of the input
+	read() (inputEntry, error)
+}
+
+// inputEntry is a RidePart read from the input, and whether it is the last one of its ride
+type inputEntry struct {
+	part calculator.RidePart
+	end  bool
+}
+
+// rejectedLine is a malformed line of the input, which is skipped and written to Options.Rejects
+type rejectedLine struct {
+	lineNumber int
+	raw        string
+	reason     error
+}
+
+func (line *rejectedLine) Error() string {
+	return fmt.Sprintf("line %d: %s", line.lineNumber, line.reason)
+}
+
+// rideSource is the RideSource of every input format, grouping the entries read into rides according to the Options
+type rideSource struct {
+	entries entryReader
+	rides   grouper
+	rejects *rejectWriter
+	// completed contains the rides that are completed, but not returned yet
+	completed [][]calculator.RidePart
+	done      bool
+}
+
+func newRideSource(entries entryReader, options Options) *rideSource {
+	return &rideSource{entries: entries, rides: newGrouper(options), rejects: newRejectWriter(options)}
+}
+
+func (source *rideSource) Next() ([]calculator.RidePart, error) {
+	for len(source.completed) == 0 {
+		if source.done {
+			return nil, io.EOF
+		}
+
+		if err := source.readEntry(); err != nil {
+			source.done = true
+			return nil, err
+		}
+	}
+
+	ride := source.completed[0]
+	source.completed = source.completed[1:]
+
+	return ride, nil
+}
+
+// readEntry reads the next entry, and adds the rides it completes to the completed ones.
+// At the end of the input, the rides that are not completed yet are added too
+func (source *rideSource) readEntry() error {
+	entry, err := source.entries.read()
+
+	if err == io.EOF {
+		source.done = true
+
+		completed, err := source.rides.flush()
+		source.completed = append(source.completed, completed...)
+		return err
+	}
+
+	var rejected *rejectedLine
+	if errors.As(err, &rejected) {
+		return source.rejects.reject(rejected)
+	}
+	if err != nil {
+		return err
+	}
+
+	completed, err := source.rides.add(entry.part, entry.end)
+	source.completed = append(source.completed, completed...)
+	return err
+}
+
+func (source *rideSource) Close() error {
+	rejectsErr := source.rejects.flush()
+
+	if err := source.rides.close(); err != nil {
+		return err
+	}
+	return rejectsErr
+}
@@ -0,0 +1,138 @@
+package parser
+
+import (
+	"context"
+	"errors"
+	"harry-pap/beat_assignment/calculator"
+	"harry-pap/beat_assignment/compression"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCSVSource_Next(t *testing.T) {
+	source := NewCSVSource(strings.NewReader("1,37.9,23.7,10\n1,37.9,23.7,20\n2,37.9,23.7,15\nx,37.9,23.7,15\n3,37.9,23.7,30\n"), Options{})
+
+	var got []int64
+	var err error
+	for {
+		var ride []calculator.RidePart
+		if ride, err = source.Next(); err != nil {
+			break
+		}
+		got = append(got, ride[0].RideID)
+	}
+
+	if want := []int64{1}; !reflect.DeepEqual(got, want) || !errors.Is(err, ErrTooManyRejects) {
+		t.Errorf("RideSource.Next() returned rides %v, %v, want %v, %v", got, err, want, ErrTooManyRejects)
+	}
+	if ride, err := source.Next(); ride != nil || err != io.EOF {
+		t.Errorf("RideSource.Next() after an error = %v, %v, want io.EOF", ride, err)
+	}
+	if err := source.Close(); err != nil {
+		t.Errorf("RideSource.Close() returned error %v", err)
+	}
+}
+
+// generatedSource is a RideSource generating count rides of two parts each, and failing with err when it is not nil
+type generatedSource struct {
+	count  int
+	err    error
+	next   int
+	closed bool
+}
+
+func (source *generatedSource) Next() ([]calculator.RidePart, error) {
+	if source.next == source.count {
+		if source.err != nil {
+			return nil, source.err
+		}
+		return nil, io.EOF
+	}
+	source.next++
+
+	return []calculator.RidePart{
+		{RideID: int64(source.next), Timestamp: 0},
+		{RideID: int64(source.next), Timestamp: 60e9},
+	}, nil
+}
+
+func (source *generatedSource) Close() error {
+	source.closed = true
+	return nil
+}
+
+func TestPushRides(t *testing.T) {
+	errGenerator := errors.New("generator_failed")
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	expiring, cancelExpiring := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancelExpiring()
+
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		source  *generatedSource
+		channel chan []calculator.RidePart
+		want    int
+		wantErr error
+	}{
+		{"every ride is pushed", context.Background(), &generatedSource{count: 3}, make(chan []calculator.RidePart, 10), 3, nil},
+		{"error stops pushing", context.Background(), &generatedSource{count: 2, err: errGenerator}, make(chan []calculator.RidePart, 10), 2, errGenerator},
+		{"cancellation stops pushing", cancelled, &generatedSource{count: 3}, make(chan []calculator.RidePart, 10), 0, context.Canceled},
+		{"cancellation stops waiting for the channel", expiring, &generatedSource{count: 3}, make(chan []calculator.RidePart), 0, context.DeadlineExceeded},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			channel := tt.channel
+
+			err := PushRides(tt.ctx, tt.source, channel)
+			close(channel)
+
+			if !errors.Is(err, tt.wantErr) || len(channel) != tt.want {
+				t.Errorf("PushRides() pushed %d rides, %v, want %d, %v", len(channel), err, tt.want, tt.wantErr)
+			}
+			if tt.source.closed {
+				t.Errorf("PushRides() closed the source")
+			}
+		})
+	}
+}
+
+func TestPushRides_blockedRead(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	// the writer of the pipe writes a ride, and then stalls, like a live stream
+	reader, writer := io.Pipe()
+	defer writer.Close()
+	go io.WriteString(writer, "1,49.143352,3.519707,1544590680\n1,49.150684,3.532212,1544590860\n2,49.143352,3.519707,1544590680\n")
+
+	channel := make(chan []calculator.RidePart, 10)
+	result := make(chan error)
+	go func() {
+		result <- PushRides(ctx, NewCSVSource(compression.NewContextReader(ctx, reader), Options{}), channel)
+	}()
+
+	select {
+	case ride := <-channel:
+		if len(ride) != 2 {
+			t.Errorf("PushRides() pushed %v, want the 2 parts of ride 1", ride)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("PushRides() pushed no ride")
+	}
+
+	cancel()
+
+	select {
+	case err := <-result:
+		if !errors.Is(err, context.Canceled) || !strings.Contains(err.Error(), "after reading 1 rides") {
+			t.Errorf("PushRides() returned error %v, want %v after 1 ride", err, context.Canceled)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("PushRides() is still blocked on the read after the cancellation")
+	}
+}
@@ -0,0 +1,117 @@
+package parser
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TimestampFormat is the format of the timestamp column of the input
+type TimestampFormat int
+
+const (
+	// TimestampSeconds is a Unix timestamp in seconds, optionally with a fraction(e.g. 1405594957.25)
+	TimestampSeconds TimestampFormat = iota
+	// TimestampMilliseconds is a Unix timestamp in milliseconds
+	TimestampMilliseconds
+	// TimestampMicroseconds is a Unix timestamp in microseconds
+	TimestampMicroseconds
+	// TimestampRFC3339 is an RFC 3339 date and time, optionally with a fraction of a second(e.g. 2014-07-17T11:02:37.25Z)
+	TimestampRFC3339
+	// TimestampAuto detects the format of each timestamp: RFC 3339 when it is not a number, otherwise
+	// seconds below 10^11, milliseconds below 10^14, and microseconds above
+	TimestampAuto
+)
+
+var timestampFormatNames = enumNames{"seconds", "milliseconds", "microseconds", "rfc3339", "auto"}
+
+// String returns the name of the format
+func (format TimestampFormat) String() string {
+	return timestampFormatNames.name(int(format))
+}
+
+// Set sets the format by its name, so that TimestampFormat can be used as a flag.Value
+func (format *TimestampFormat) Set(name string) error {
+	value, err := timestampFormatNames.parse("timestamp format", name)
+	if err != nil {
+		return err
+	}
+
+	*format = TimestampFormat(value)
+	return nil
+}
+
+// parseTimestamp parses the text in the given format, into a Unix timestamp in nanoseconds
+func parseTimestamp(text string, format TimestampFormat) (int64, error) {
+	switch format {
+	case TimestampSeconds:
+		return parseUnix(text, time.Second)
+	case TimestampMilliseconds:
+		return parseUnix(text, time.Millisecond)
+	case TimestampMicroseconds:
+		return parseUnix(text, time.Microsecond)
+	case TimestampRFC3339:
+		return parseRFC3339(text)
+	case TimestampAuto:
+		return parseTimestamp(text, detectTimestampFormat(text))
+	default:
+		return 0, fmt.Errorf("unknown timestamp format %v", format)
+	}
+}
+
+func detectTimestampFormat(text string) TimestampFormat {
+	whole, _, _ := strings.Cut(text, ".")
+
+	value, err := strconv.ParseInt(whole, 10, 64)
+	switch {
+	case err != nil:
+		return TimestampRFC3339
+	case value > -1e11 && value < 1e11:
+		return TimestampSeconds
+	case value > -1e14 && value < 1e14:
+		return TimestampMilliseconds
+	default:
+		return TimestampMicroseconds
+	}
+}
+
+// parseUnix parses a whole number of units, optionally followed by a fraction, into nanoseconds.
+// The fraction is parsed as digits, to avoid the rounding errors of floating point numbers
+func parseUnix(text string, unit time.Duration) (int64, error) {
+	whole, fraction, hasFraction := strings.Cut(text, ".")
+
+	value, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil || value > math.MaxInt64/int64(unit) || value < math.MinInt64/int64(unit) {
+		return 0, fmt.Errorf("invalid timestamp %q", text)
+	}
+	nanoseconds := value * int64(unit)
+
+	if !hasFraction {
+		return nanoseconds, nil
+	}
+
+	var fractionNanoseconds int64
+	scale := int64(unit)
+	for _, digit := range fraction {
+		if digit < '0' || digit > '9' {
+			return 0, fmt.Errorf("invalid timestamp %q", text)
+		}
+		scale /= 10
+		fractionNanoseconds += int64(digit-'0') * scale
+	}
+
+	if strings.HasPrefix(whole, "-") {
+		return nanoseconds - fractionNanoseconds, nil
+	}
+	return nanoseconds + fractionNanoseconds, nil
+}
+
+func parseRFC3339(text string) (int64, error) {
+	ts, err := time.Parse(time.RFC3339Nano, text)
+	if err != nil || ts.Year() < 1678 || ts.Year() > 2261 {
+		return 0, fmt.Errorf("invalid timestamp %q", text)
+	}
+	return ts.UnixNano(), nil
+}
@@ -0,0 +1,66 @@
+package parser
+
+import "testing"
+
+func TestParseTimestamp(t *testing.T) {
+	type args struct {
+		text   string
+		format TimestampFormat
+	}
+	type want struct {
+		res     int64
+		wantErr bool
+	}
+	tests := []struct {
+		name string
+		args args
+		want want
+	}{
+		{"seconds", args{"1405594957", TimestampSeconds}, want{1405594957000000000, false}},
+		{"seconds with fraction", args{"1405594957.25", TimestampSeconds}, want{1405594957250000000, false}},
+		{"negative seconds with fraction", args{"-1.5", TimestampSeconds}, want{-1500000000, false}},
+		{"milliseconds", args{"1405594957123", TimestampMilliseconds}, want{1405594957123000000, false}},
+		{"microseconds", args{"1405594957123456", TimestampMicroseconds}, want{1405594957123456000, false}},
+		{"rfc3339", args{"2014-07-17T11:02:37Z", TimestampRFC3339}, want{1405594957000000000, false}},
+		{"rfc3339 with fraction and offset", args{"2014-07-17T14:02:37.5+03:00", TimestampRFC3339}, want{1405594957500000000, false}},
+		{"auto seconds", args{"1405594957", TimestampAuto}, want{1405594957000000000, false}},
+		{"auto milliseconds", args{"1405594957123", TimestampAuto}, want{1405594957123000000, false}},
+		{"auto microseconds", args{"1405594957123456", TimestampAuto}, want{1405594957123456000, false}},
+		{"auto rfc3339", args{"2014-07-17T11:02:37Z", TimestampAuto}, want{1405594957000000000, false}},
+		{"seconds beyond the nanosecond range", args{"9223372037", TimestampSeconds}, want{0, true}},
+		{"rfc3339 beyond the nanosecond range", args{"2300-01-01T00:00:00Z", TimestampRFC3339}, want{0, true}},
+		{"malformed fraction", args{"1405594957.2x", TimestampSeconds}, want{0, true}},
+		{"rfc3339 as seconds", args{"2014-07-17T11:02:37Z", TimestampSeconds}, want{0, true}},
+		{"malformed auto", args{"yesterday", TimestampAuto}, want{0, true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, err := parseTimestamp(tt.args.text, tt.args.format); got != tt.want.res || (err != nil) != tt.want.wantErr {
+				t.Errorf("parseTimestamp() = %v, %v, want %v, error: %v", got, err, tt.want.res, tt.want.wantErr)
+			}
+		})
+	}
+}
+
+func TestTimestampFormat_Set(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    TimestampFormat
+		wantErr bool
+	}{
+		{"seconds", TimestampSeconds, false},
+		{"milliseconds", TimestampMilliseconds, false},
+		{"microseconds", TimestampMicroseconds, false},
+		{"RFC3339", TimestampRFC3339, false},
+		{"auto", TimestampAuto, false},
+		{"fortnights", TimestampSeconds, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got TimestampFormat
+			if err := got.Set(tt.name); got != tt.want || (err != nil) != tt.wantErr {
+				t.Errorf("TimestampFormat.Set() = %v, %v, want %v, error: %v", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
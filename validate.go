@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"harry-pap/beat_assignment/calculator"
+	"harry-pap/beat_assignment/compression"
+	"io"
+	"os"
+	"strconv"
+)
+
+// validateUsage is printed before the flags of the validate command, by -h and on invalid arguments
+const validateUsage = `Usage: fare-calculator validate [flags] [source [report]]
+
+Checks the rides of the source without pricing them, and writes a CSV report of their issues: the malformed rows,
+the rides whose points are not sorted by timestamp, the points discarded as GPS anomalies, and the rides without a
+valid segment. The report is written to the standard output, unless a report path is given, and a summary to the
+standard error. The exit status is non-zero when an issue is found.
+
+Flags:
+`
+
+// The kinds of issues found by the validate command
+const (
+	issueMalformedRow      = "malformed_row"
+	issueUnsortedRide      = "unsorted_ride"
+	issueGPSAnomaly        = "gps_anomaly"
+	issueNotEnoughSegments = "not_enough_segments"
+)
+
+// issueKinds are the kinds of issues, in the order they are summarized
+var issueKinds = []string{issueMalformedRow, issueUnsortedRide, issueGPSAnomaly, issueNotEnoughSegments}
+
+// issueHeader contains the column names of the validation report
+var issueHeader = []string{"id_ride", "line_number", "issue", "detail"}
+
+// validationIssue is an issue found by the validate command, in a ride, or in a line of the source
+type validationIssue struct {
+	rideID     string
+	lineNumber string
+	kind       string
+	detail     string
+}
+
+// ToStringSlice returns the fields of the issue, for the columns of issueHeader
+func (issue validationIssue) ToStringSlice() []string {
+	return []string{issue.rideID, issue.lineNumber, issue.kind, issue.detail}
+}
+
+// runValidate runs the validate command, checking the rides of the source one at a time, without the workers of the
+// estimate command. The malformed rows are reported as they are rejected by the parser, between the issues of the rides
+func runValidate(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("validate", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), validateUsage)
+		flags.PrintDefaults()
+	}
+	inputPath := flags.String("input", "", "source to read the rides from, - for the standard input, instead of the first argument")
+	outputPath := flags.String("output", "", "report to write the issues to, - for the standard output(default), instead of the second argument")
+	var source sourceFlags
+	source.register(flags, -1)
+	if err := flags.Parse(args); err != nil {
+		return fmt.Errorf("invalid arguments: %w", err)
+	}
+
+	argsErr := resolvePositionals(flags.Args(), positional{name: "source", value: inputPath}, positional{name: "report", value: outputPath, optional: true})
+	if *outputPath == "" {
+		*outputPath = compression.StdStream
+	}
+	if argsErr == nil {
+		argsErr = validateArguments(flags, source.numberArguments(), []string{*inputPath}, *outputPath)
+	}
+	if argsErr != nil {
+		flags.Usage()
+		return fmt.Errorf("invalid arguments: %w", argsErr)
+	}
+
+	reportFile, err := compression.CreateOutput(*outputPath)
+	if err != nil {
+		return err
+	}
+	report := csv.NewWriter(reportFile)
+	report.Write(issueHeader)
+
+	counts := make(map[string]int, len(issueKinds))
+	rideCount, pointCount := 0, 0
+	writeIssue := func(issue validationIssue) {
+		counts[issue.kind]++
+		report.Write(issue.ToStringSlice())
+	}
+
+	options := source.options()
+	options.OnReject = func(lineNumber int, _ string, reason error) {
+		writeIssue(validationIssue{lineNumber: strconv.Itoa(lineNumber), kind: issueMalformedRow, detail: reason.Error()})
+	}
+
+	rides, err := source.open(ctx, *inputPath, options)
+	if err != nil {
+		reportFile.Close()
+		return err
+	}
+
+	var parseErr error
+	for parseErr == nil {
+		if parseErr = ctx.Err(); parseErr != nil {
+			break
+		}
+
+		var parts []calculator.RidePart
+		if parts, parseErr = rides.Next(); parseErr != nil {
+			break
+		}
+
+		rideCount++
+		pointCount += len(parts)
+		for _, issue := range checkRide(parts) {
+			writeIssue(issue)
+		}
+	}
+	if parseErr == io.EOF {
+		parseErr = nil
+	}
+
+	if closeErr := rides.Close(); parseErr == nil {
+		parseErr = closeErr
+	}
+
+	report.Flush()
+	reportErr := errors.Join(report.Error(), reportFile.Close())
+
+	fmt.Fprintf(os.Stderr, "Validated %d rides of %d points:", rideCount, pointCount)
+	issueCount := 0
+	for _, kind := range issueKinds {
+		fmt.Fprintf(os.Stderr, " %d %s", counts[kind], kind)
+		issueCount += counts[kind]
+	}
+	fmt.Fprintln(os.Stderr)
+
+	err = errors.Join(parseErr, reportErr)
+	if err == nil && issueCount > 0 {
+		err = fmt.Errorf("%d issues found", issueCount)
+	}
+	return err
+}
+
+// checkRide returns the issues of the parts of a ride: whether they are not sorted by timestamp, and, once sorted,
+// whether some of them are discarded as GPS anomalies(over 100 km/h), or the ride has no valid segment
+func checkRide(parts []calculator.RidePart) []validationIssue {
+	id := strconv.FormatInt(parts[0].RideID, 10)
+	var result []validationIssue
+
+	sorted, reordered := calculator.ReorderParts(parts, 0)
+	if reordered > 0 {
+		result = append(result, validationIssue{rideID: id, kind: issueUnsortedRide,
+			detail: fmt.Sprintf("%d of %d points are before a point preceding them", reordered, len(parts))})
+	}
+
+	segments := calculator.GetValidSegments(sorted)
+	if len(segments) == 0 {
+		return append(result, validationIssue{rideID: id, kind: issueNotEnoughSegments,
+			detail: fmt.Sprintf("no valid segment in %d points", len(parts))})
+	}
+
+	if discarded := len(sorted) - len(segments) - 1; discarded > 0 {
+		result = append(result, validationIssue{rideID: id, kind: issueGPSAnomaly,
+			detail: fmt.Sprintf("%d of %d points discarded, over 100 km/h", discarded, len(parts))})
+	}
+	return result
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func Test_validate(t *testing.T) {
+	tests := []struct {
+		name      string
+		inputFile string
+		want      string
+		wantErr   string
+	}{
+		{"valid rides", "testdata/sample.csv", "", ""},
+		{"unsorted ride", "testdata/sample_unsorted.csv", "1,,unsorted_ride,1 of 4 points are before a point preceding them\n", "1 issues found"},
+		{"malformed row", "testdata/malformed.csv", `,3,malformed_row,"invalid timestamp ""not_a_timestamp"""` + "\n", "1 issues found"},
+		{"ride without a valid segment", "testdata/sample_failed.csv", "2,,not_enough_segments,no valid segment in 1 points\n", "1 issues found"},
+		{"GPS anomalies", "testdata/paths.csv", "gps_anomaly", "issues found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := filepath.Join(t.TempDir(), "report.csv")
+
+			err := run(context.Background(), []string{"validate", tt.inputFile, report})
+
+			if (tt.wantErr == "" && err != nil) || (tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr))) {
+				t.Errorf("run() returned error %v, want %q", err, tt.wantErr)
+			}
+
+			bytes, _ := os.ReadFile(report)
+			if !strings.HasPrefix(string(bytes), "id_ride,line_number,issue,detail\n") || !strings.Contains(string(bytes), tt.want) {
+				t.Errorf("Bad report, expected it to contain `%v`, got `%v`", tt.want, string(bytes))
+			}
+		})
+	}
+}
+
+func Test_validate_arguments(t *testing.T) {
+	if err := run(context.Background(), []string{"validate", "testdata/missing.csv"}); err == nil || !strings.Contains(err.Error(), "cannot read source") {
+		t.Errorf("run() returned error %v, want a missing source", err)
+	}
+}